@@ -0,0 +1,203 @@
+package z
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Principal struct {
+	Subject string
+	Method  string
+	Roles   []string
+	Scopes  []string
+	Claims  map[string]any
+}
+
+func (z *Z) Principal() *Principal {
+	return PrincipalFromContext(z.r.Context())
+}
+
+func (z *Z) SetPrincipal(principal *Principal) {
+	z.setContextValue(principalContextKey, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey).(*Principal)
+	return principal
+}
+
+func secureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+func unauthorized(z *Z, challenge string) {
+	if challenge != "" {
+		z.rw.Header().Set("WWW-Authenticate", challenge)
+	}
+	z.String(http.StatusUnauthorized, "Unauthorized")
+}
+
+type BasicAuthConfig struct {
+	Realm     string
+	Validator func(z *Z, username, password string) bool
+}
+
+func (middlewaresRegistry) BasicAuth(users map[string]string) MiddlewareFunc {
+	return Middlewares.BasicAuthWithCfg(BasicAuthConfig{
+		Validator: func(z *Z, username, password string) bool {
+			expected, ok := users[username]
+			if !ok {
+				secureCompare(password, password)
+				return false
+			}
+			return secureCompare(password, expected)
+		},
+	})
+}
+
+func (middlewaresRegistry) BasicAuthWithCfg(cfg BasicAuthConfig) MiddlewareFunc {
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, cfg.Realm)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			username, password, ok := z.r.BasicAuth()
+			if !ok || cfg.Validator == nil || !cfg.Validator(z, username, password) {
+				unauthorized(z, challenge)
+				return
+			}
+			z.SetPrincipal(&Principal{Subject: username, Method: "basic"})
+			next(z)
+		}
+	}
+}
+
+type KeyAuthConfig struct {
+	Lookup    string
+	Validator func(z *Z, key string) (*Principal, error)
+}
+
+func (middlewaresRegistry) KeyAuth(validator func(z *Z, key string) (*Principal, error)) MiddlewareFunc {
+	return Middlewares.KeyAuthWithCfg(KeyAuthConfig{Validator: validator})
+}
+
+func (middlewaresRegistry) KeyAuthWithCfg(cfg KeyAuthConfig) MiddlewareFunc {
+	if cfg.Lookup == "" {
+		cfg.Lookup = "header:Authorization:Bearer "
+	}
+	extract := credentialExtractor(cfg.Lookup)
+	challenge := bearerChallenge(cfg.Lookup, "")
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			key := extract(z)
+			if key == "" || cfg.Validator == nil {
+				unauthorized(z, challenge)
+				return
+			}
+			principal, err := cfg.Validator(z, key)
+			if err != nil || principal == nil {
+				unauthorized(z, bearerChallenge(cfg.Lookup, "invalid_token"))
+				return
+			}
+			if principal.Method == "" {
+				principal.Method = "key"
+			}
+			z.SetPrincipal(principal)
+			next(z)
+		}
+	}
+}
+
+func StaticAPIKeys(keys map[string]string) func(z *Z, key string) (*Principal, error) {
+	return func(z *Z, key string) (*Principal, error) {
+		var subject string
+		for candidate, s := range keys {
+			if secureCompare(key, candidate) {
+				subject = s
+			}
+		}
+		if subject == "" {
+			return nil, ErrInvalidCredentials
+		}
+		return &Principal{Subject: subject, Method: "key"}, nil
+	}
+}
+
+func bearerChallenge(lookup, errorCode string) string {
+	if !strings.Contains(lookup, "header:Authorization:Bearer") {
+		return ""
+	}
+	if errorCode == "" {
+		return "Bearer"
+	}
+	return fmt.Sprintf("Bearer error=%q", errorCode)
+}
+
+func credentialExtractor(lookup string) func(z *Z) string {
+	var extractors []func(z *Z) string
+	for _, source := range strings.Split(lookup, ",") {
+		parts := strings.SplitN(strings.TrimSpace(source), ":", 3)
+		if len(parts) < 2 {
+			continue
+		}
+		name := parts[1]
+		prefix := ""
+		if len(parts) == 3 {
+			prefix = parts[2]
+		}
+
+		switch parts[0] {
+		case "header":
+			extractors = append(extractors, func(z *Z) string {
+				value := z.r.Header.Get(name)
+				if prefix == "" {
+					return value
+				}
+				if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+					return strings.TrimSpace(value[len(prefix):])
+				}
+				return ""
+			})
+		case "query":
+			extractors = append(extractors, func(z *Z) string {
+				return z.r.URL.Query().Get(name)
+			})
+		case "form":
+			extractors = append(extractors, func(z *Z) string {
+				return z.r.PostFormValue(name)
+			})
+		case "cookie":
+			extractors = append(extractors, func(z *Z) string {
+				cookie, err := z.r.Cookie(name)
+				if err != nil {
+					return ""
+				}
+				return cookie.Value
+			})
+		}
+	}
+
+	return func(z *Z) string {
+		for _, extract := range extractors {
+			if value := extract(z); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,138 @@
+package z
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	mw := Middlewares.BasicAuth(map[string]string{"alice": "s3cret"})
+
+	var principal *Principal
+	handler := mw(func(z *Z) { principal = z.Principal() })
+
+	cases := []struct {
+		name     string
+		user     string
+		pass     string
+		setAuth  bool
+		wantCode int
+	}{
+		{"valid", "alice", "s3cret", true, http.StatusOK},
+		{"wrong password", "alice", "nope", true, http.StatusUnauthorized},
+		{"unknown user", "bob", "s3cret", true, http.StatusUnauthorized},
+		{"missing", "", "", false, http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			principal = nil
+			req := httptest.NewRequest("GET", "/", nil)
+			if c.setAuth {
+				req.SetBasicAuth(c.user, c.pass)
+			}
+			rr := httptest.NewRecorder()
+			handler(&Z{rw: rr, r: req})
+
+			if rr.Code != c.wantCode {
+				t.Fatalf("Expected status %d, got %d", c.wantCode, rr.Code)
+			}
+			if c.wantCode == http.StatusUnauthorized {
+				if got := rr.Header().Get("WWW-Authenticate"); got != `Basic realm="Restricted", charset="UTF-8"` {
+					t.Errorf("Unexpected challenge %q", got)
+				}
+				return
+			}
+			if principal == nil || principal.Subject != "alice" || principal.Method != "basic" {
+				t.Errorf("Expected alice principal, got %+v", principal)
+			}
+		})
+	}
+}
+
+func TestKeyAuth_Lookups(t *testing.T) {
+	validator := StaticAPIKeys(map[string]string{"key-123": "billing-service"})
+
+	cases := []struct {
+		name   string
+		lookup string
+		setup  func(r *http.Request)
+	}{
+		{"bearer header", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer key-123") }},
+		{"custom header", "header:X-API-Key", func(r *http.Request) { r.Header.Set("X-API-Key", "key-123") }},
+		{"query", "query:api_key", func(r *http.Request) { r.URL.RawQuery = "api_key=key-123" }},
+		{"cookie", "cookie:api_key", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "api_key", Value: "key-123"}) }},
+		{"fallback", "header:X-API-Key,query:api_key", func(r *http.Request) { r.URL.RawQuery = "api_key=key-123" }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mw := Middlewares.KeyAuthWithCfg(KeyAuthConfig{Lookup: c.lookup, Validator: validator})
+			req := httptest.NewRequest("GET", "/", nil)
+			c.setup(req)
+			rr := httptest.NewRecorder()
+
+			var principal *Principal
+			mw(func(z *Z) { principal = z.Principal() })(&Z{rw: rr, r: req})
+
+			if principal == nil || principal.Subject != "billing-service" || principal.Method != "key" {
+				t.Errorf("Expected billing-service principal, got %+v (status %d)", principal, rr.Code)
+			}
+		})
+	}
+}
+
+func TestKeyAuth_Rejections(t *testing.T) {
+	mw := Middlewares.KeyAuth(StaticAPIKeys(map[string]string{"key-123": "svc"}))
+	handler := mw(func(z *Z) { t.Error("handler should not run") })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("Expected bearer challenge for missing key, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rr = httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") != `Bearer error="invalid_token"` {
+		t.Errorf("Expected invalid_token challenge, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+
+	failing := Middlewares.KeyAuth(func(z *Z, key string) (*Principal, error) { return nil, errors.New("db down") })
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer key-123")
+	rr = httptest.NewRecorder()
+	failing(func(z *Z) { t.Error("handler should not run") })(&Z{rw: rr, r: req})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 on validator error, got %d", rr.Code)
+	}
+}
+
+func TestSetPrincipal(t *testing.T) {
+	z := &Z{r: httptest.NewRequest("GET", "/", nil)}
+	if z.Principal() != nil {
+		t.Fatal("Expected no principal by default")
+	}
+	z.SetPrincipal(&Principal{Subject: "svc"})
+	if PrincipalFromContext(z.Context()).Subject != "svc" {
+		t.Error("Principal should be stored in the request context")
+	}
+}
+
+func TestRateLimitByUser_DefaultsToPrincipal(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	z := &Z{r: req}
+
+	keyFunc := RateLimitByUser(nil)
+	if got := keyFunc(z); got != "ip:10.0.0.1" {
+		t.Errorf("Expected IP fallback without a principal, got %q", got)
+	}
+	z.SetPrincipal(&Principal{Subject: "alice"})
+	if got := keyFunc(z); got != "user:alice" {
+		t.Errorf("Expected principal subject key, got %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+type Policy interface {
+	Authorize(z *Z) error
+}
+
+type PolicyFunc func(z *Z) error
+
+func (f PolicyFunc) Authorize(z *Z) error {
+	return f(z)
+}
+
+func Authorize(policies ...Policy) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			for _, policy := range policies {
+				if err := policy.Authorize(z); err != nil {
+					denyAccess(z, err)
+					return
+				}
+			}
+			next(z)
+		}
+	}
+}
+
+func RequireRoles(roles ...string) MiddlewareFunc {
+	return Authorize(RolesPolicy(roles...))
+}
+
+func RequireScopes(scopes ...string) MiddlewareFunc {
+	return Authorize(ScopesPolicy(scopes...))
+}
+
+func RolesPolicy(roles ...string) Policy {
+	return PolicyFunc(func(z *Z) error {
+		principal := z.Principal()
+		if principal == nil {
+			return ErrUnauthenticated
+		}
+		for _, role := range roles {
+			if slices.Contains(principal.Roles, role) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: requires one of roles %s", ErrForbidden, strings.Join(roles, ", "))
+	})
+}
+
+func ScopesPolicy(scopes ...string) Policy {
+	return PolicyFunc(func(z *Z) error {
+		principal := z.Principal()
+		if principal == nil {
+			return ErrUnauthenticated
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(principal.Scopes, scope) {
+				return &insufficientScopeError{scopes: scopes}
+			}
+		}
+		return nil
+	})
+}
+
+type insufficientScopeError struct {
+	scopes []string
+}
+
+func (e *insufficientScopeError) Error() string {
+	return fmt.Sprintf("%s: requires scopes %s", ErrForbidden, strings.Join(e.scopes, " "))
+}
+
+func (e *insufficientScopeError) Unwrap() error {
+	return ErrForbidden
+}
+
+func denyAccess(z *Z, err error) {
+	if errors.Is(err, ErrUnauthenticated) {
+		unauthorized(z, "")
+		return
+	}
+	var scopeErr *insufficientScopeError
+	if errors.As(err, &scopeErr) {
+		z.rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopeErr.scopes, " ")))
+	}
+	z.String(http.StatusForbidden, "Forbidden")
+}
@@ -0,0 +1,101 @@
+package z
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveWithPrincipal(app *App, method, path string, principal *Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if principal != nil {
+		req = req.WithContext(context.WithValue(req.Context(), principalContextKey, principal))
+	}
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRequireRoles(t *testing.T) {
+	app := New()
+	app.GET("/admin", func(z *Z) { z.Ok("ok") }, RequireRoles("admin", "owner"))
+
+	cases := []struct {
+		name      string
+		principal *Principal
+		want      int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"missing role", &Principal{Subject: "u", Roles: []string{"viewer"}}, http.StatusForbidden},
+		{"has one of the roles", &Principal{Subject: "u", Roles: []string{"owner"}}, http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if rr := serveWithPrincipal(app, "GET", "/admin", c.principal); rr.Code != c.want {
+				t.Errorf("Expected status %d, got %d", c.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	app := New()
+	app.POST("/orders", func(z *Z) { z.Ok("ok") }, RequireScopes("orders:read", "orders:write"))
+
+	rr := serveWithPrincipal(app, "POST", "/orders", &Principal{Scopes: []string{"orders:read"}})
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	if got := rr.Header().Get("WWW-Authenticate"); got != `Bearer error="insufficient_scope", scope="orders:read orders:write"` {
+		t.Errorf("Unexpected challenge %q", got)
+	}
+
+	rr = serveWithPrincipal(app, "POST", "/orders", &Principal{Scopes: []string{"orders:write", "orders:read"}})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+type ownerPolicy struct{}
+
+func (ownerPolicy) Authorize(z *Z) error {
+	principal := z.Principal()
+	if principal == nil {
+		return ErrUnauthenticated
+	}
+	if principal.Subject != z.PathValue("user") {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func TestAuthorizePolicyOnGroup(t *testing.T) {
+	app := New()
+	users := app.Group("/users/{user}", Authorize(ownerPolicy{}))
+	users.GET("/profile", func(z *Z) { z.Ok("profile") })
+	users.GET("/billing", func(z *Z) { z.Ok("billing") }, RequireRoles("billing"))
+
+	if rr := serveWithPrincipal(app, "GET", "/users/alice/profile", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for anonymous, got %d", rr.Code)
+	}
+	if rr := serveWithPrincipal(app, "GET", "/users/alice/profile", &Principal{Subject: "bob"}); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for another user, got %d", rr.Code)
+	}
+	if rr := serveWithPrincipal(app, "GET", "/users/alice/profile", &Principal{Subject: "alice"}); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for the owner, got %d", rr.Code)
+	}
+	if rr := serveWithPrincipal(app, "GET", "/users/alice/billing", &Principal{Subject: "alice"}); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected route guard to apply after group policy, got %d", rr.Code)
+	}
+}
+
+func TestPolicyFunc_CustomErrorIsForbidden(t *testing.T) {
+	mw := Authorize(PolicyFunc(func(z *Z) error { return errors.New("nope") }))
+	rr := httptest.NewRecorder()
+	mw(func(z *Z) { t.Error("handler should not run") })(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for policy errors, got %d", rr.Code)
+	}
+}
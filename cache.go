@@ -0,0 +1,540 @@
+package z
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultCacheMaxBytes = 64 << 20
+
+var ErrCacheNotConfigured = errors.New("cache middleware not configured")
+
+type CachedResponse struct {
+	Status     int
+	Header     http.Header
+	Body       []byte
+	Stored     time.Time
+	Expires    time.Time
+	StaleUntil time.Time
+	Tags       []string
+	Vary       []string
+}
+
+type CacheStore interface {
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	Set(ctx context.Context, key string, resp *CachedResponse) error
+	Delete(ctx context.Context, key string) error
+	PurgeTag(ctx context.Context, tag string) error
+}
+
+type CacheConfig struct {
+	Store                CacheStore
+	TTL                  time.Duration
+	StaleWhileRevalidate time.Duration
+	QueryParams          []string
+	KeyPrefix            string
+	Statuses             []int
+	Skip                 func(z *Z) bool
+}
+
+func (z *Z) CacheTags(tags ...string) {
+	for _, tag := range tags {
+		z.rw.Header().Add("Cache-Tag", tag)
+	}
+}
+
+func (z *Z) PurgeCache(keys ...string) error {
+	cache, ok := z.r.Context().Value(cacheContextKey).(*responseCache)
+	if !ok {
+		return ErrCacheNotConfigured
+	}
+	for _, key := range keys {
+		if method, path, ok := strings.Cut(key, " "); ok && strings.HasPrefix(path, "/") {
+			key = method + " " + strings.ToLower(z.Host()) + path
+		}
+		if err := cache.cfg.Store.Delete(z.r.Context(), cache.cfg.KeyPrefix+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (z *Z) PurgeCacheTags(tags ...string) error {
+	cache, ok := z.r.Context().Value(cacheContextKey).(*responseCache)
+	if !ok {
+		return ErrCacheNotConfigured
+	}
+	for _, tag := range tags {
+		if err := cache.cfg.Store.PurgeTag(z.r.Context(), tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type cacheCall struct {
+	wg   sync.WaitGroup
+	key  string
+	resp *CachedResponse
+}
+
+type responseCache struct {
+	cfg   CacheConfig
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+func (middlewaresRegistry) Cache(cfg CacheConfig) MiddlewareFunc {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryCacheStore(DefaultCacheMaxBytes)
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.Statuses == nil {
+		cfg.Statuses = []int{http.StatusOK}
+	}
+	cache := &responseCache{cfg: cfg, calls: map[string]*cacheCall{}}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			z.setContextValue(cacheContextKey, cache)
+
+			if (z.r.Method != http.MethodGet && z.r.Method != http.MethodHead) ||
+				z.r.Header.Get("Authorization") != "" || (cfg.Skip != nil && cfg.Skip(z)) {
+				next(z)
+				return
+			}
+			directives := parseCacheControl(z.r.Header.Get("Cache-Control"))
+			if _, ok := directives["no-store"]; ok {
+				next(z)
+				return
+			}
+
+			base := cfg.KeyPrefix + cacheKey(z, cfg.QueryParams)
+			if _, ok := directives["no-cache"]; !ok {
+				key, entry, err := cache.lookup(z.r, base)
+				if err != nil {
+					slog.Error("Cache lookup failed", "err", err)
+				}
+				if entry != nil && cacheAcceptable(entry, directives) {
+					now := time.Now()
+					switch {
+					case now.Before(entry.Expires):
+						serveCached(z, entry, "HIT")
+						return
+					case now.Before(entry.StaleUntil):
+						serveCached(z, entry, "STALE")
+						cache.revalidate(z, base, key, next)
+						return
+					}
+				}
+			}
+
+			cache.fill(z, base, next)
+		}
+	}
+}
+
+func (c *responseCache) lookup(r *http.Request, base string) (string, *CachedResponse, error) {
+	entry, err := c.cfg.Store.Get(r.Context(), base)
+	if err != nil || entry == nil {
+		return base, nil, err
+	}
+	if len(entry.Vary) == 0 {
+		return base, entry, nil
+	}
+	key := varyKey(base, entry.Vary, r)
+	entry, err = c.cfg.Store.Get(r.Context(), key)
+	return key, entry, err
+}
+
+func (c *responseCache) fill(z *Z, base string, next HandlerFunc) {
+	c.mu.Lock()
+	if call, ok := c.calls[base]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		if call.resp != nil && varyKey(base, call.resp.Vary, z.r) == call.key {
+			serveCached(z, call.resp, "HIT")
+			return
+		}
+		next(z)
+		return
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.calls[base] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, base)
+		c.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	z.rw.Header().Set("X-Cache", "MISS")
+	writer := &cacheWriter{ResponseWriter: z.rw, status: http.StatusOK}
+	z.rw = writer
+	next(z)
+	call.key, call.resp = c.store(z.r, base, writer)
+}
+
+func (c *responseCache) revalidate(z *Z, base, key string, next HandlerFunc) {
+	c.mu.Lock()
+	if _, ok := c.calls[base]; ok {
+		c.mu.Unlock()
+		return
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.calls[base] = call
+	c.mu.Unlock()
+
+	r := z.r.Clone(context.WithoutCancel(z.r.Context()))
+	r.Header.Del("If-None-Match")
+	r.Header.Del("If-Modified-Since")
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				slog.Error("Cache revalidation panicked", "err", recovered, "key", key)
+			}
+			c.mu.Lock()
+			delete(c.calls, base)
+			c.mu.Unlock()
+			call.wg.Done()
+		}()
+
+		writer := &cacheWriter{ResponseWriter: &discardResponseWriter{header: http.Header{}}, status: http.StatusOK}
+		next(&Z{rw: writer, r: r, app: z.app, route: z.route})
+		call.key, call.resp = c.store(r, base, writer)
+	}()
+}
+
+func (c *responseCache) store(r *http.Request, base string, writer *cacheWriter) (string, *CachedResponse) {
+	if writer.streaming || !slices.Contains(c.cfg.Statuses, writer.status) {
+		return "", nil
+	}
+	header := writer.Header().Clone()
+	header.Del("X-Cache")
+	if header.Get("Set-Cookie") != "" {
+		return "", nil
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return "", nil
+		}
+	}
+	ttl := c.cfg.TTL
+	if v, ok := directives["s-maxage"]; ok {
+		ttl = parseDirectiveSeconds(v)
+	} else if v, ok := directives["max-age"]; ok {
+		ttl = parseDirectiveSeconds(v)
+	}
+	if ttl <= 0 {
+		return "", nil
+	}
+	stale := c.cfg.StaleWhileRevalidate
+	if v, ok := directives["stale-while-revalidate"]; ok {
+		stale = parseDirectiveSeconds(v)
+	}
+
+	var vary []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return "", nil
+			}
+			if name != "" && !slices.Contains(vary, name) {
+				vary = append(vary, name)
+			}
+		}
+	}
+	var tags []string
+	for _, value := range header.Values("Cache-Tag") {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	now := time.Now()
+	resp := &CachedResponse{
+		Status:     writer.status,
+		Header:     header,
+		Body:       writer.body.Bytes(),
+		Stored:     now,
+		Expires:    now.Add(ttl),
+		StaleUntil: now.Add(ttl + stale),
+		Tags:       tags,
+	}
+
+	ctx := r.Context()
+	key := base
+	if len(vary) > 0 {
+		marker := &CachedResponse{Stored: now, Expires: resp.Expires, StaleUntil: resp.StaleUntil, Tags: tags, Vary: vary}
+		if err := c.cfg.Store.Set(ctx, base, marker); err != nil {
+			slog.Error("Cache store failed", "err", err)
+			return "", nil
+		}
+		key = varyKey(base, vary, r)
+		resp.Vary = vary
+	}
+	if err := c.cfg.Store.Set(ctx, key, resp); err != nil {
+		slog.Error("Cache store failed", "err", err)
+		return "", nil
+	}
+	return key, resp
+}
+
+func serveCached(z *Z, resp *CachedResponse, status string) {
+	header := z.rw.Header()
+	for name, values := range resp.Header {
+		header[name] = slices.Clone(values)
+	}
+	header.Set("Age", strconv.Itoa(int(time.Since(resp.Stored).Seconds())))
+	header.Set("X-Cache", status)
+	z.rw.WriteHeader(resp.Status)
+	if z.r.Method != http.MethodHead {
+		z.rw.Write(resp.Body)
+	}
+}
+
+func cacheAcceptable(resp *CachedResponse, directives map[string]string) bool {
+	if v, ok := directives["max-age"]; ok && time.Since(resp.Stored) > parseDirectiveSeconds(v) {
+		return false
+	}
+	return true
+}
+
+func cacheKey(z *Z, params []string) string {
+	key := z.r.Method + " " + strings.ToLower(z.Host()) + z.r.URL.Path
+	query := z.r.URL.Query()
+	if params != nil {
+		selected := url.Values{}
+		for _, p := range params {
+			if values, ok := query[p]; ok {
+				selected[p] = values
+			}
+		}
+		query = selected
+	}
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
+
+func varyKey(base string, vary []string, r *http.Request) string {
+	if len(vary) == 0 {
+		return base
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range vary {
+		b.WriteString("|")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return directives
+}
+
+func parseDirectiveSeconds(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+type cacheWriter struct {
+	http.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+	streaming   bool
+}
+
+func (cw *cacheWriter) WriteHeader(statusCode int) {
+	if !cw.wroteHeader {
+		cw.status = statusCode
+		cw.wroteHeader = true
+	}
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cacheWriter) Flush() {
+	cw.streaming = true
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(int) {}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *CachedResponse
+	size int64
+}
+
+type MemoryCacheStore struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+	tags     map[string]map[string]struct{}
+	now      func() time.Time
+}
+
+func NewMemoryCacheStore(maxBytes int64) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+		tags:     map[string]map[string]struct{}{},
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if s.now().After(entry.resp.StaleUntil) && s.now().After(entry.resp.Expires) {
+		s.remove(el)
+		return nil, nil
+	}
+	s.order.MoveToFront(el)
+	return entry.resp, nil
+}
+
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	size := int64(len(key) + len(resp.Body))
+	for name, values := range resp.Header {
+		size += int64(len(name))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return nil
+	}
+
+	s.items[key] = s.order.PushFront(&memoryCacheEntry{key: key, resp: resp, size: size})
+	s.size += size
+	for _, tag := range resp.Tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]struct{}{}
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+
+	for s.maxBytes > 0 && s.size > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *MemoryCacheStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+func (s *MemoryCacheStore) PurgeTag(ctx context.Context, tag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.tags[tag] {
+		if el, ok := s.items[key]; ok {
+			s.remove(el)
+		}
+	}
+	delete(s.tags, tag)
+	return nil
+}
+
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func (s *MemoryCacheStore) remove(el *list.Element) {
+	entry := s.order.Remove(el).(*memoryCacheEntry)
+	delete(s.items, entry.key)
+	s.size -= entry.size
+	for _, tag := range entry.resp.Tags {
+		if keys := s.tags[tag]; keys != nil {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
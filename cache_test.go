@@ -0,0 +1,260 @@
+package z
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func cacheRequest(handler HandlerFunc, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+	return rr
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	handler := Middlewares.Cache(CacheConfig{QueryParams: []string{"page"}})(func(z *Z) {
+		n := calls.Add(1)
+		z.Ok(fmt.Sprintf("call %d", n))
+	})
+
+	rr := cacheRequest(handler, "/items?page=1&utm=a", nil)
+	if rr.Header().Get("X-Cache") != "MISS" || rr.Body.String() != "call 1" {
+		t.Fatalf("Expected MISS, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+	rr = cacheRequest(handler, "/items?page=1&utm=b", nil)
+	if rr.Header().Get("X-Cache") != "HIT" || rr.Body.String() != "call 1" || rr.Header().Get("Age") == "" {
+		t.Fatalf("Expected HIT, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+	if rr = cacheRequest(handler, "/items?page=2", nil); rr.Body.String() != "call 2" {
+		t.Fatalf("Expected different page to miss, got %q", rr.Body.String())
+	}
+	if rr = cacheRequest(handler, "/items?page=1", map[string]string{"Cache-Control": "no-cache"}); rr.Body.String() != "call 3" {
+		t.Fatalf("Expected no-cache to bypass lookup, got %q", rr.Body.String())
+	}
+	if rr = cacheRequest(handler, "/items?page=1", nil); rr.Body.String() != "call 3" {
+		t.Fatalf("Expected no-cache response to refresh the entry, got %q", rr.Body.String())
+	}
+	if rr = cacheRequest(handler, "/items?page=1", map[string]string{"Authorization": "Bearer x"}); rr.Body.String() != "call 4" {
+		t.Fatalf("Expected authorized request to bypass cache, got %q", rr.Body.String())
+	}
+}
+
+func TestCacheMiddleware_ResponseDirectives(t *testing.T) {
+	cases := []struct {
+		name    string
+		prepare func(z *Z)
+		cached  bool
+	}{
+		{"no-store", func(z *Z) { z.SetHeader("Cache-Control", "no-store") }, false},
+		{"private", func(z *Z) { z.SetHeader("Cache-Control", "private, max-age=60") }, false},
+		{"max-age zero", func(z *Z) { z.SetHeader("Cache-Control", "max-age=0") }, false},
+		{"set-cookie", func(z *Z) { z.SetCookie(&http.Cookie{Name: "a", Value: "b"}) }, false},
+		{"vary star", func(z *Z) { z.SetHeader("Vary", "*") }, false},
+		{"public", func(z *Z) { z.SetHeader("Cache-Control", "public, max-age=60") }, true},
+		{"error status", func(z *Z) { z.rw.WriteHeader(http.StatusInternalServerError) }, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls int
+			handler := Middlewares.Cache(CacheConfig{})(func(z *Z) {
+				calls++
+				c.prepare(z)
+				z.ResponseWriter().Write([]byte("body"))
+			})
+			cacheRequest(handler, "/", nil)
+			cacheRequest(handler, "/", nil)
+			if cached := calls == 1; cached != c.cached {
+				t.Fatalf("Expected cached=%v, handler ran %d times", c.cached, calls)
+			}
+		})
+	}
+}
+
+func TestCacheMiddleware_Vary(t *testing.T) {
+	var calls int
+	handler := Middlewares.Cache(CacheConfig{})(func(z *Z) {
+		calls++
+		z.SetHeader("Vary", "Accept-Language")
+		z.Ok(z.Request().Header.Get("Accept-Language"))
+	})
+
+	for _, c := range []struct{ lang, want string }{{"en", "en"}, {"fr", "fr"}, {"en", "en"}, {"fr", "fr"}} {
+		if rr := cacheRequest(handler, "/", map[string]string{"Accept-Language": c.lang}); rr.Body.String() != c.want {
+			t.Fatalf("Expected %q, got %q", c.want, rr.Body.String())
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Expected one handler call per variant, got %d", calls)
+	}
+}
+
+func TestCacheMiddleware_TTLAndStaleWhileRevalidate(t *testing.T) {
+	var calls atomic.Int32
+	revalidated := make(chan struct{}, 1)
+	handler := Middlewares.Cache(CacheConfig{TTL: 50 * time.Millisecond, StaleWhileRevalidate: time.Minute})(func(z *Z) {
+		n := calls.Add(1)
+		z.Ok(fmt.Sprintf("v%d", n))
+		if n > 1 {
+			revalidated <- struct{}{}
+		}
+	})
+
+	cacheRequest(handler, "/", nil)
+	time.Sleep(60 * time.Millisecond)
+
+	rr := cacheRequest(handler, "/", nil)
+	if rr.Header().Get("X-Cache") != "STALE" || rr.Body.String() != "v1" {
+		t.Fatalf("Expected stale v1, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+	select {
+	case <-revalidated:
+	case <-time.After(time.Second):
+		t.Fatal("Expected background revalidation")
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if rr = cacheRequest(handler, "/", nil); rr.Header().Get("X-Cache") != "HIT" || rr.Body.String() != "v2" {
+		t.Fatalf("Expected fresh v2, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+}
+
+func TestCacheMiddleware_Coalescing(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := Middlewares.Cache(CacheConfig{})(func(z *Z) {
+		calls.Add(1)
+		<-release
+		z.Ok("slow")
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies[i] = cacheRequest(handler, "/slow", nil).Body.String()
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("Expected handler to run once, ran %d times", calls.Load())
+	}
+	for _, body := range bodies {
+		if body != "slow" {
+			t.Fatalf("Expected every caller to get the response, got %q", body)
+		}
+	}
+}
+
+func TestCacheMiddleware_Purge(t *testing.T) {
+	var calls int
+	cache := Middlewares.Cache(CacheConfig{})
+	get := cache(func(z *Z) {
+		calls++
+		z.CacheTags("articles", "article:"+z.Request().URL.Path)
+		z.Ok("article")
+	})
+	var purgeErr error
+	purgeKey := cache(func(z *Z) { purgeErr = z.PurgeCache("GET /a") })
+	purgeTag := cache(func(z *Z) { purgeErr = z.PurgeCacheTags("articles") })
+
+	cacheRequest(get, "/a", nil)
+	cacheRequest(get, "/b", nil)
+	purgeKey(&Z{rw: httptest.NewRecorder(), r: httptest.NewRequest("POST", "/purge", nil)})
+	cacheRequest(get, "/a", nil)
+	cacheRequest(get, "/b", nil)
+	if calls != 3 || purgeErr != nil {
+		t.Fatalf("Expected only /a to be purged, handler ran %d times (%v)", calls, purgeErr)
+	}
+
+	purgeTag(&Z{rw: httptest.NewRecorder(), r: httptest.NewRequest("POST", "/purge", nil)})
+	cacheRequest(get, "/a", nil)
+	cacheRequest(get, "/b", nil)
+	if calls != 5 {
+		t.Fatalf("Expected tag purge to clear both, handler ran %d times", calls)
+	}
+
+	if err := (&Z{r: httptest.NewRequest("GET", "/", nil)}).PurgeCache("x"); err != ErrCacheNotConfigured {
+		t.Fatalf("Expected ErrCacheNotConfigured, got %v", err)
+	}
+}
+
+func TestCacheMiddleware_KeysIncludeHost(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.Cache(CacheConfig{}))
+	app.Host("{tenant}.example.com").GET("/me", func(z *Z) { z.Ok("data for " + z.PathValue("tenant")) })
+	app.Host("{tenant}.example.com").POST("/purge", func(z *Z) { z.PurgeCache("GET /me") })
+
+	get := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, req)
+		return rr
+	}
+
+	get("acme.example.com")
+	if rr := get("globex.example.com"); rr.Body.String() != "data for globex" || rr.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("Expected a separate entry per host, got %q %s", rr.Body.String(), rr.Header().Get("X-Cache"))
+	}
+	if rr := get("ACME.example.com"); rr.Body.String() != "data for acme" || rr.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("Expected a case-insensitive host hit, got %q %s", rr.Body.String(), rr.Header().Get("X-Cache"))
+	}
+
+	req := httptest.NewRequest("POST", "/purge", nil)
+	req.Host = "acme.example.com"
+	app.ServeHTTP(httptest.NewRecorder(), req)
+	if rr := get("acme.example.com"); rr.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected purge to clear the acme entry, got %s", rr.Header().Get("X-Cache"))
+	}
+	if rr := get("globex.example.com"); rr.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected purge to keep the globex entry, got %s", rr.Header().Get("X-Cache"))
+	}
+}
+
+func TestMemoryCacheStore_LRU(t *testing.T) {
+	store := NewMemoryCacheStore(250)
+	ctx := context.Background()
+	entry := func() *CachedResponse {
+		return &CachedResponse{Body: make([]byte, 100), Expires: time.Now().Add(time.Minute)}
+	}
+
+	store.Set(ctx, "a", entry())
+	store.Set(ctx, "b", entry())
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", entry())
+
+	if resp, _ := store.Get(ctx, "b"); resp != nil {
+		t.Fatal("Expected least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if resp, _ := store.Get(ctx, key); resp == nil {
+			t.Fatalf("Expected %q to be cached", key)
+		}
+	}
+	if store.Set(ctx, "huge", &CachedResponse{Body: make([]byte, 1000)}); store.Len() != 2 {
+		t.Fatal("Expected oversized entry to be rejected")
+	}
+
+	clock := &fakeClock{t: time.Now()}
+	store.now = clock.Now
+	clock.Advance(2 * time.Minute)
+	if resp, _ := store.Get(ctx, "a"); resp != nil || store.Len() != 1 {
+		t.Fatal("Expected expired entry to be dropped")
+	}
+}
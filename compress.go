@@ -0,0 +1,266 @@
+package z
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultCompressMinLength = 1024
+
+var DefaultCompressSkipContentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/pdf",
+	"application/octet-stream",
+}
+
+type CompressConfig struct {
+	Level            int
+	MinLength        int
+	Encodings        []string
+	SkipContentTypes []string
+	Skip             func(z *Z) bool
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (middlewaresRegistry) Compress() MiddlewareFunc {
+	return Middlewares.CompressWithCfg(CompressConfig{})
+}
+
+func (middlewaresRegistry) CompressWithCfg(cfg CompressConfig) MiddlewareFunc {
+	if cfg.Level == 0 {
+		cfg.Level = flate.DefaultCompression
+	}
+	if cfg.MinLength == 0 {
+		cfg.MinLength = DefaultCompressMinLength
+	}
+	if cfg.Encodings == nil {
+		cfg.Encodings = []string{"gzip", "deflate"}
+	}
+	if cfg.SkipContentTypes == nil {
+		cfg.SkipContentTypes = DefaultCompressSkipContentTypes
+	}
+
+	pools := map[string]*sync.Pool{}
+	for _, encoding := range cfg.Encodings {
+		switch encoding {
+		case "gzip":
+			pools[encoding] = &sync.Pool{New: func() any {
+				w, err := gzip.NewWriterLevel(io.Discard, cfg.Level)
+				if err != nil {
+					w = gzip.NewWriter(io.Discard)
+				}
+				return w
+			}}
+		case "deflate":
+			pools[encoding] = &sync.Pool{New: func() any {
+				w, err := flate.NewWriter(io.Discard, cfg.Level)
+				if err != nil {
+					w, _ = flate.NewWriter(io.Discard, flate.DefaultCompression)
+				}
+				return w
+			}}
+		}
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			z.rw.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(z.r.Header.Get("Accept-Encoding"), cfg.Encodings)
+			pool := pools[encoding]
+			if pool == nil || z.r.Method == http.MethodHead || (cfg.Skip != nil && cfg.Skip(z)) {
+				next(z)
+				return
+			}
+
+			rw := z.rw
+			writer := &compressWriter{ResponseWriter: rw, cfg: &cfg, encoding: encoding, pool: pool}
+			z.rw = writer
+			defer func() {
+				z.rw = rw
+				writer.Close()
+			}()
+			next(z)
+		}
+	}
+}
+
+func negotiateEncoding(header string, supported []string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		for i, encoding := range supported {
+			if name != encoding && name != "*" {
+				continue
+			}
+			if q > bestQ || (q == bestQ && i < indexOf(supported, best)) {
+				best, bestQ = encoding, q
+			}
+			if name != "*" {
+				break
+			}
+		}
+	}
+	return best
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return len(values)
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	cfg         *CompressConfig
+	encoding    string
+	pool        *sync.Pool
+	writer      compressor
+	buf         []byte
+	status      int
+	wroteHeader bool
+	decided     bool
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	if statusCode >= 100 && statusCode < 200 {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.status = statusCode
+	cw.wroteHeader = true
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified || statusCode == http.StatusPartialContent {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.writer != nil {
+			return cw.writer.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.cfg.MinLength {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+
+	if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		matchContentType(header.Get("Content-Type"), cw.cfg.SkipContentTypes) {
+		compress = false
+	}
+
+	if compress {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		cw.writer = cw.pool.Get().(compressor)
+		cw.writer.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.writer != nil {
+		_, err = cw.writer.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if cw.writer != nil {
+		cw.writer.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Close() error {
+	if !cw.wroteHeader {
+		return nil
+	}
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.writer == nil {
+		return nil
+	}
+	err := cw.writer.Close()
+	cw.writer.Reset(io.Discard)
+	cw.pool.Put(cw.writer)
+	cw.writer = nil
+	return err
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
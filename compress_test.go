@@ -0,0 +1,152 @@
+package z
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"gzip", "deflate"}
+	cases := map[string]string{
+		"":                          "",
+		"gzip":                      "gzip",
+		"deflate, gzip":             "gzip",
+		"deflate;q=1.0, gzip;q=0.5": "deflate",
+		"gzip;q=0, deflate":         "deflate",
+		"*":                         "gzip",
+		"br":                        "",
+		"identity":                  "",
+		"GZIP;q=0.8, *;q=0.1":       "gzip",
+	}
+	for header, want := range cases {
+		if got := negotiateEncoding(header, supported); got != want {
+			t.Errorf("%q: expected %q, got %q", header, want, got)
+		}
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+	mw := Middlewares.Compress()
+
+	cases := []struct {
+		name         string
+		accept       string
+		contentType  string
+		body         string
+		wantEncoding string
+	}{
+		{"gzip", "gzip", "text/plain", large, "gzip"},
+		{"deflate", "deflate", "text/plain", large, "deflate"},
+		{"sniffed", "gzip", "", large, "gzip"},
+		{"no accept", "", "text/plain", large, ""},
+		{"small body", "gzip", "text/plain", "tiny", ""},
+		{"compressed type", "gzip", "image/png", large, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", c.accept)
+			rr := httptest.NewRecorder()
+			mw(func(z *Z) {
+				if c.contentType != "" {
+					z.SetHeader("Content-Type", c.contentType)
+				}
+				z.SetHeader("Content-Length", "999")
+				for i := 0; i < len(c.body); i += 100 {
+					z.ResponseWriter().Write([]byte(c.body[i:min(i+100, len(c.body))]))
+				}
+			})(&Z{rw: rr, r: req})
+
+			if got := rr.Header().Get("Content-Encoding"); got != c.wantEncoding {
+				t.Fatalf("Expected encoding %q, got %q", c.wantEncoding, got)
+			}
+			if rr.Header().Get("Vary") != "Accept-Encoding" {
+				t.Fatal("Expected Vary: Accept-Encoding")
+			}
+			if rr.Header().Get("Content-Type") == "" {
+				t.Fatal("Expected Content-Type to be set")
+			}
+
+			var reader io.Reader = rr.Body
+			switch c.wantEncoding {
+			case "gzip":
+				if rr.Header().Get("Content-Length") != "" {
+					t.Fatal("Expected Content-Length to be removed")
+				}
+				gz, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader = gz
+			case "deflate":
+				reader = flate.NewReader(rr.Body)
+			}
+			body, _ := io.ReadAll(reader)
+			if string(body) != c.body {
+				t.Fatalf("Body mismatch: got %d bytes", len(body))
+			}
+		})
+	}
+}
+
+func TestCompressMiddleware_Flush(t *testing.T) {
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	Middlewares.Compress()(func(z *Z) {
+		z.SetHeader("Content-Type", "text/event-stream")
+		z.ResponseWriter().Write([]byte("data: one\n\n"))
+		http.NewResponseController(z.ResponseWriter()).Flush()
+
+		if !rr.Flushed {
+			t.Fatal("Expected underlying writer to be flushed")
+		}
+		gz, err := gzip.NewReader(strings.NewReader(rr.Body.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 64)
+		n, _ := gz.Read(buf)
+		if string(buf[:n]) != "data: one\n\n" {
+			t.Fatalf("Expected flushed event, got %q", buf[:n])
+		}
+	})(&Z{rw: rr, r: req})
+
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected gzip encoding for streamed response")
+	}
+}
+
+func TestCompressMiddleware_NoContent(t *testing.T) {
+	req := httptest.NewRequest("DELETE", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	Middlewares.Compress()(func(z *Z) { z.ResponseWriter().WriteHeader(http.StatusNoContent) })(&Z{rw: rr, r: req})
+
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Content-Encoding") != "" || rr.Body.Len() != 0 {
+		t.Fatalf("Unexpected response: %d %v %q", rr.Code, rr.Header(), rr.Body.String())
+	}
+}
+
+func TestCompressMiddleware_Recovery(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.RecoveryWithCfg(RecoveryConfig{}))
+	app.Use(Middlewares.Compress())
+	app.GET("/", func(z *Z) { panic("boom") })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError || rr.Body.String() != "Internal Server Error" {
+		t.Fatalf("Expected 500 from Recovery, got %d %q", rr.Code, rr.Body.String())
+	}
+}
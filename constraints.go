@@ -0,0 +1,312 @@
+package z
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var enumConstraint = regexp.MustCompile(`^[\w.-]+(\|[\w.-]+)+$`)
+
+type ParamConstraint struct {
+	Kind string
+	Expr string
+	re   *regexp.Regexp
+}
+
+func (c ParamConstraint) Match(value string) bool {
+	switch c.Kind {
+	case "int":
+		if value == "" || strings.TrimLeft(value, "0123456789") != "" {
+			return false
+		}
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case "uuid":
+		return isUUID(value)
+	}
+	return c.re.MatchString(value)
+}
+
+func (c ParamConstraint) String() string {
+	if c.Expr == "" {
+		return c.Kind
+	}
+	return c.Expr
+}
+
+func parseRoutePattern(pattern string) (string, map[string]ParamConstraint, error) {
+	var constraints map[string]ParamConstraint
+	segments := splitPatternSegments(pattern)
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name, expr, ok := strings.Cut(segment[1:len(segment)-1], ":")
+		if !ok {
+			continue
+		}
+
+		var c ParamConstraint
+		switch expr {
+		case "int", "uuid":
+			c = ParamConstraint{Kind: expr}
+		default:
+			kind, source := "regex", expr
+			if enumConstraint.MatchString(expr) {
+				alternatives := strings.Split(expr, "|")
+				for j, alternative := range alternatives {
+					alternatives[j] = regexp.QuoteMeta(alternative)
+				}
+				kind, source = "enum", strings.Join(alternatives, "|")
+			}
+			re, err := regexp.Compile("^(?:" + source + ")$")
+			if err != nil {
+				return "", nil, fmt.Errorf("param %s: %w", name, err)
+			}
+			c = ParamConstraint{Kind: kind, Expr: expr, re: re}
+		}
+
+		if constraints == nil {
+			constraints = map[string]ParamConstraint{}
+		}
+		constraints[strings.TrimSuffix(name, "...")] = c
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), constraints, nil
+}
+
+func splitPatternSegments(pattern string) []string {
+	var segments []string
+	depth, start := 0, 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				segments = append(segments, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, pattern[start:])
+}
+
+func displayPattern(route *Route) string {
+	if len(route.Constraints) == 0 {
+		return route.Pattern
+	}
+	segments := strings.Split(route.Pattern, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := segment[1 : len(segment)-1]
+		if c, ok := route.Constraints[strings.TrimSuffix(name, "...")]; ok {
+			segments[i] = "{" + name + ":" + c.String() + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func patternShape(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") || segment == "{$}" {
+			continue
+		}
+		if strings.HasSuffix(segment, "...}") {
+			segments[i] = "{...}"
+		} else {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func wildcardNames(pattern string) []string {
+	var names []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") || segment == "{$}" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(segment[1:len(segment)-1], "..."))
+	}
+	return names
+}
+
+type routeCandidate struct {
+	route   *Route
+	names   []string
+	handler HandlerFunc
+}
+
+type routeSlot struct {
+	method     string
+	pattern    string
+	names      []string
+	candidates []*routeCandidate
+}
+
+func (c *routeCandidate) rank() int {
+	switch {
+	case c.route.Host != "" && hostShape(c.route.Host) == c.route.Host:
+		return 0
+	case c.route.Host != "":
+		return 1
+	case len(c.route.Constraints) > 0 || c.route.versioning != nil:
+		return 2
+	}
+	return 3
+}
+
+func (slot *routeSlot) add(candidate *routeCandidate) {
+	i := len(slot.candidates)
+	for i > 0 && slot.candidates[i-1].rank() > candidate.rank() {
+		i--
+	}
+	slot.candidates = slices.Insert(slot.candidates, i, candidate)
+}
+
+func routesConflict(a, b *Route) bool {
+	if hostShape(a.Host) != hostShape(b.Host) || len(a.Constraints) > 0 || len(b.Constraints) > 0 {
+		return false
+	}
+	return a.versioning == b.versioning && a.Version == b.Version
+}
+
+func (c *routeCandidate) matches(values []string) bool {
+	for i, name := range c.names {
+		if constraint, ok := c.route.Constraints[name]; ok && !constraint.Match(values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (app *App) dispatch(slot *routeSlot, w http.ResponseWriter, r *http.Request) {
+	values := make([]string, len(slot.names))
+	for i, name := range slot.names {
+		values[i] = r.PathValue(name)
+	}
+	if app.serveSlot(slot, values, w, r) {
+		return
+	}
+	for _, fallback := range app.fallbackSlots(slot, r) {
+		if app.serveSlot(fallback.slot, fallback.values, w, r) {
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+type slotMatch struct {
+	slot   *routeSlot
+	values []string
+	rank   []int
+}
+
+func (app *App) fallbackSlots(current *routeSlot, r *http.Request) []slotMatch {
+	var matches []slotMatch
+	for _, slot := range app.slots {
+		if slot == current || (slot.method != r.Method && (r.Method != http.MethodHead || slot.method != http.MethodGet)) {
+			continue
+		}
+		if values, ok := matchPattern(slot.pattern, r.URL.EscapedPath()); ok {
+			matches = append(matches, slotMatch{slot: slot, values: values, rank: patternRank(slot.pattern)})
+		}
+	}
+	slices.SortFunc(matches, func(a, b slotMatch) int {
+		if c := slices.Compare(a.rank, b.rank); c != 0 {
+			return c
+		}
+		return strings.Compare(a.slot.pattern, b.slot.pattern)
+	})
+	return matches
+}
+
+func matchPattern(pattern, path string) ([]string, bool) {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	var values []string
+	for i, segment := range patternSegments {
+		last := i == len(patternSegments)-1
+		switch {
+		case segment == "{$}":
+			return values, last && len(pathSegments) == i+1 && pathSegments[i] == ""
+		case last && segment == "":
+			return values, i < len(pathSegments)
+		case i >= len(pathSegments):
+			return nil, false
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "...}"):
+			value, err := url.PathUnescape(strings.Join(pathSegments[i:], "/"))
+			return append(values, value), err == nil
+		}
+
+		value, err := url.PathUnescape(pathSegments[i])
+		if err != nil {
+			return nil, false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if value == "" {
+				return nil, false
+			}
+			values = append(values, value)
+		} else if value != segment {
+			return nil, false
+		}
+	}
+	return values, len(pathSegments) == len(patternSegments)
+}
+
+func patternRank(pattern string) []int {
+	segments := strings.Split(pattern, "/")
+	rank := make([]int, len(segments))
+	for i, segment := range segments {
+		switch {
+		case strings.HasSuffix(segment, "...}") || (i == len(segments)-1 && segment == ""):
+			rank[i] = 2
+		case strings.HasPrefix(segment, "{") && segment != "{$}":
+			rank[i] = 1
+		}
+	}
+	return rank
+}
+
+func (app *App) serveSlot(slot *routeSlot, values []string, w http.ResponseWriter, r *http.Request) bool {
+	host, hostResolved := "", false
+	for _, candidate := range slot.candidates {
+		if !candidate.matches(values) {
+			continue
+		}
+		if v := candidate.route.versioning; v != nil && v.resolve(r) != candidate.route.Version {
+			continue
+		}
+		var hostValues map[string]string
+		if candidate.route.Host != "" {
+			if !hostResolved {
+				host, hostResolved = requestHost(app, r), true
+			}
+			var ok bool
+			if hostValues, ok = matchHost(candidate.route.Host, host); !ok {
+				continue
+			}
+		}
+		for i, name := range candidate.names {
+			r.SetPathValue(name, values[i])
+		}
+		for name, value := range hostValues {
+			r.SetPathValue(name, value)
+		}
+		candidate.handler(&Z{rw: w, r: r, app: app, route: candidate.route})
+		return true
+	}
+	return false
+}
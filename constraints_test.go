@@ -0,0 +1,114 @@
+package z
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteConstraints(t *testing.T) {
+	app := New()
+	app.GET("/users/{id:int}", func(z *Z) { z.Ok("int:" + z.PathValue("id")) })
+	app.GET("/users/{uid:uuid}", func(z *Z) { z.Ok("uuid:" + z.PathValue("uid")) })
+	app.GET("/users/{name}", func(z *Z) { z.Ok("name:" + z.PathValue("name")) })
+	app.GET("/users/me", func(z *Z) { z.Ok("me") })
+	app.GET("/posts/{status:draft|published}", func(z *Z) { z.Ok("status:" + z.PathValue("status")) })
+	app.GET("/tags/{slug:[a-z0-9-]{2,}}", func(z *Z) { z.Ok("slug:" + z.PathValue("slug")) })
+	app.GET("/files/{path...:[a-z/]+}", func(z *Z) { z.Ok("path:" + z.PathValue("path")) })
+	app.GET("/releases/{v:1.0|2.0}", func(z *Z) { z.Ok("release:" + z.PathValue("v")) })
+
+	cases := []struct {
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{"/users/42", http.StatusOK, "int:42"},
+		{"/users/0190b5c6-1e2a-7c3d-8e4f-5a6b7c8d9e0f", http.StatusOK, "uuid:0190b5c6-1e2a-7c3d-8e4f-5a6b7c8d9e0f"},
+		{"/users/alice", http.StatusOK, "name:alice"},
+		{"/users/+12", http.StatusOK, "name:+12"},
+		{"/users/-12", http.StatusOK, "name:-12"},
+		{"/users/me", http.StatusOK, "me"},
+		{"/posts/draft", http.StatusOK, "status:draft"},
+		{"/posts/deleted", http.StatusNotFound, ""},
+		{"/tags/go-lang", http.StatusOK, "slug:go-lang"},
+		{"/tags/x", http.StatusNotFound, ""},
+		{"/tags/UPPER", http.StatusNotFound, ""},
+		{"/files/a/b", http.StatusOK, "path:a/b"},
+		{"/files/a/B", http.StatusNotFound, ""},
+		{"/releases/1.0", http.StatusOK, "release:1.0"},
+		{"/releases/1x0", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, httptest.NewRequest("GET", c.target, nil))
+		if rr.Code != c.wantCode || (c.wantBody != "" && rr.Body.String() != c.wantBody) {
+			t.Errorf("%s: expected %d %q, got %d %q", c.target, c.wantCode, c.wantBody, rr.Code, rr.Body.String())
+		}
+	}
+
+	if err := app.Err(); err != nil {
+		t.Fatalf("Expected constrained routes not to conflict, got %v", err)
+	}
+}
+
+func TestRouteConstraints_FallThrough(t *testing.T) {
+	app := New()
+	app.GET("/files/{id:int}", func(z *Z) { z.Ok("id:" + z.PathValue("id")) })
+	app.GET("/files/{path...}", func(z *Z) { z.Ok("path:" + z.PathValue("path")) })
+	app.GET("/docs/{name:[a-z]+}/raw", func(z *Z) { z.Ok("raw:" + z.PathValue("name")) })
+	app.GET("/docs/", func(z *Z) { z.Ok("docs") })
+
+	cases := map[string]string{
+		"/files/12":       "id:12",
+		"/files/abc":      "path:abc",
+		"/files/a%20b/c":  "path:a b/c",
+		"/docs/intro/raw": "raw:intro",
+		"/docs/42/raw":    "docs",
+	}
+	for target, want := range cases {
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != want {
+			t.Errorf("%s: expected %q, got %d %q", target, want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("HEAD", "/files/abc", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected HEAD to fall through to the GET route, got %d", rr.Code)
+	}
+}
+
+func TestRouteConstraints_Introspection(t *testing.T) {
+	app := New()
+	app.GET("/orders/{id:int}", func(z *Z) {}).WithName("order")
+	app.GET("/orders/{state:open|closed}", func(z *Z) {})
+	app.GET("/bad/{id:[}", func(z *Z) {})
+
+	routes := app.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %d", len(routes))
+	}
+	if r := routes[0]; r.Pattern != "/orders/{id}" || r.Constraints["id"].Kind != "int" {
+		t.Fatalf("Unexpected route: %+v", r)
+	}
+	if c := routes[1].Constraints["state"]; c.Kind != "enum" || c.Expr != "open|closed" {
+		t.Fatalf("Unexpected constraint: %+v", c)
+	}
+	if !errors.Is(app.Err(), ErrInvalidRoute) {
+		t.Fatalf("Expected invalid regex to be reported, got %v", app.Err())
+	}
+
+	var b strings.Builder
+	app.PrintRoutes(&b)
+	if !strings.Contains(b.String(), "/orders/{id:int}") || !strings.Contains(b.String(), "/orders/{state:open|closed}") {
+		t.Fatalf("Expected constraints in route table:\n%s", b.String())
+	}
+
+	if _, err := app.URL("order", map[string]string{"id": "abc"}, nil); !errors.Is(err, ErrInvalidRouteParam) {
+		t.Fatalf("Expected ErrInvalidRouteParam, got %v", err)
+	}
+}
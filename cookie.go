@@ -0,0 +1,197 @@
+package z
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCookie = errors.New("invalid cookie")
+	ErrCookieExpired = errors.New("cookie expired")
+)
+
+const minCookieKeyLength = 32
+
+type cookieKey struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+type SecureCookie struct {
+	keys   []cookieKey
+	MaxAge time.Duration
+}
+
+func NewSecureCookie(keys ...[]byte) (*SecureCookie, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one cookie key is required")
+	}
+
+	sc := &SecureCookie{}
+	for i, key := range keys {
+		if len(key) < minCookieKeyLength {
+			return nil, fmt.Errorf("cookie key %d must be at least %d bytes", i, minCookieKeyLength)
+		}
+		block, err := aes.NewCipher(deriveCookieKey(key, "encrypt"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		sc.keys = append(sc.keys, cookieKey{aead: aead, mac: deriveCookieKey(key, "sign")})
+	}
+	return sc, nil
+}
+
+func deriveCookieKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("z-cookie-" + purpose))
+	return mac.Sum(nil)
+}
+
+func cookieMAC(key []byte, name, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (sc *SecureCookie) Sign(name, value string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + strconv.FormatInt(time.Now().Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(cookieMAC(sc.keys[0].mac, name, payload))
+}
+
+func (sc *SecureCookie) Verify(name, signed string) (string, error) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", ErrInvalidCookie
+	}
+	payload := signed[:i]
+	signature, err := base64.RawURLEncoding.DecodeString(signed[i+1:])
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	valid := false
+	for _, key := range sc.keys {
+		if hmac.Equal(cookieMAC(key.mac, name, payload), signature) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", ErrInvalidCookie
+	}
+
+	encoded, ts, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	issued, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	if err := sc.checkAge(issued); err != nil {
+		return "", err
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	return string(value), nil
+}
+
+func (sc *SecureCookie) Encrypt(name, value string) (string, error) {
+	aead := sc.keys[0].aead
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+8+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	plaintext := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(plaintext, uint64(time.Now().Unix()))
+	plaintext = append(plaintext, value...)
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (sc *SecureCookie) Decrypt(name, encrypted string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, key := range sc.keys {
+		aead := key.aead
+		if len(data) < aead.NonceSize() {
+			return "", ErrInvalidCookie
+		}
+		plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(name))
+		if err != nil {
+			continue
+		}
+		if len(plaintext) < 8 {
+			return "", ErrInvalidCookie
+		}
+		if err := sc.checkAge(int64(binary.BigEndian.Uint64(plaintext[:8]))); err != nil {
+			return "", err
+		}
+		return string(plaintext[8:]), nil
+	}
+	return "", ErrInvalidCookie
+}
+
+func (sc *SecureCookie) checkAge(issued int64) error {
+	if sc.MaxAge > 0 && time.Since(time.Unix(issued, 0)) > sc.MaxAge {
+		return ErrCookieExpired
+	}
+	return nil
+}
+
+func (z *Z) SetSignedCookie(sc *SecureCookie, cookie *http.Cookie) {
+	signed := *cookie
+	signed.Value = sc.Sign(cookie.Name, cookie.Value)
+	z.SetCookie(&signed)
+}
+
+func (z *Z) SignedCookie(sc *SecureCookie, name string) (string, error) {
+	cookie, err := z.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return sc.Verify(name, cookie.Value)
+}
+
+func (z *Z) SetEncryptedCookie(sc *SecureCookie, cookie *http.Cookie) error {
+	value, err := sc.Encrypt(cookie.Name, cookie.Value)
+	if err != nil {
+		return err
+	}
+	encrypted := *cookie
+	encrypted.Value = value
+	z.SetCookie(&encrypted)
+	return nil
+}
+
+func (z *Z) EncryptedCookie(sc *SecureCookie, name string) (string, error) {
+	cookie, err := z.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return sc.Decrypt(name, cookie.Value)
+}
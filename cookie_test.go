@@ -0,0 +1,120 @@
+package z
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testCookieKey  = bytes.Repeat([]byte("k"), 32)
+	testCookieKey2 = bytes.Repeat([]byte("n"), 32)
+)
+
+func TestNewSecureCookie_ShortKey(t *testing.T) {
+	if _, err := NewSecureCookie([]byte("short")); err == nil {
+		t.Fatal("Expected error for short key")
+	}
+	if _, err := NewSecureCookie(); err == nil {
+		t.Fatal("Expected error for missing key")
+	}
+}
+
+func TestSecureCookie_SignVerify(t *testing.T) {
+	sc, _ := NewSecureCookie(testCookieKey)
+
+	signed := sc.Sign("user", "alice")
+	value, err := sc.Verify("user", signed)
+	if err != nil || value != "alice" {
+		t.Fatalf("Expected alice, got %q (%v)", value, err)
+	}
+
+	if _, err := sc.Verify("other", signed); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("Expected ErrInvalidCookie for renamed cookie, got %v", err)
+	}
+
+	tampered := "Ym9i" + signed[strings.IndexByte(signed, '.'):]
+	if _, err := sc.Verify("user", tampered); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("Expected ErrInvalidCookie for tampered value, got %v", err)
+	}
+}
+
+func TestSecureCookie_EncryptDecrypt(t *testing.T) {
+	sc, _ := NewSecureCookie(testCookieKey)
+
+	encrypted, err := sc.Encrypt("data", "secret value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(encrypted, "secret") {
+		t.Fatal("Expected value to be encrypted")
+	}
+	value, err := sc.Decrypt("data", encrypted)
+	if err != nil || value != "secret value" {
+		t.Fatalf("Expected secret value, got %q (%v)", value, err)
+	}
+
+	if _, err := sc.Decrypt("other", encrypted); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("Expected ErrInvalidCookie for renamed cookie, got %v", err)
+	}
+	if _, err := sc.Decrypt("data", encrypted[:len(encrypted)-2]+"AA"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("Expected ErrInvalidCookie for tampered value, got %v", err)
+	}
+}
+
+func TestSecureCookie_KeyRotation(t *testing.T) {
+	old, _ := NewSecureCookie(testCookieKey)
+	rotated, _ := NewSecureCookie(testCookieKey2, testCookieKey)
+
+	signed := old.Sign("user", "alice")
+	if value, err := rotated.Verify("user", signed); err != nil || value != "alice" {
+		t.Fatalf("Expected old signature to verify, got %q (%v)", value, err)
+	}
+	encrypted, _ := old.Encrypt("user", "alice")
+	if value, err := rotated.Decrypt("user", encrypted); err != nil || value != "alice" {
+		t.Fatalf("Expected old ciphertext to decrypt, got %q (%v)", value, err)
+	}
+
+	if _, err := old.Verify("user", rotated.Sign("user", "alice")); err == nil {
+		t.Fatal("Expected new signature to fail with retired keys only")
+	}
+}
+
+func TestSecureCookie_MaxAge(t *testing.T) {
+	sc, _ := NewSecureCookie(testCookieKey)
+	sc.MaxAge = time.Minute
+
+	encrypted, _ := sc.Encrypt("data", "v")
+	signed := sc.Sign("data", "v")
+	if _, err := sc.Decrypt("data", encrypted); err != nil {
+		t.Fatalf("Expected fresh cookie to be valid, got %v", err)
+	}
+
+	sc.MaxAge = time.Nanosecond
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := sc.Decrypt("data", encrypted); !errors.Is(err, ErrCookieExpired) {
+		t.Fatalf("Expected ErrCookieExpired, got %v", err)
+	}
+	if _, err := sc.Verify("data", signed); !errors.Is(err, ErrCookieExpired) {
+		t.Fatalf("Expected ErrCookieExpired, got %v", err)
+	}
+}
+
+func TestZ_SignedCookie(t *testing.T) {
+	sc, _ := NewSecureCookie(testCookieKey)
+
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)}
+	z.SetSignedCookie(sc, &http.Cookie{Name: "user", Value: "alice"})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(rr.Result().Cookies()[0])
+	value, err := (&Z{r: req}).SignedCookie(sc, "user")
+	if err != nil || value != "alice" {
+		t.Fatalf("Expected alice, got %q (%v)", value, err)
+	}
+}
@@ -0,0 +1,203 @@
+package z
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrCSRFTokenMissing = errors.New("csrf token missing")
+	ErrCSRFTokenInvalid = errors.New("csrf token invalid")
+	ErrCSRFOrigin       = errors.New("csrf origin not allowed")
+)
+
+const csrfTokenLength = 32
+
+type CSRFMode int
+
+const (
+	CSRFDoubleSubmit CSRFMode = iota
+	CSRFSynchronizer
+)
+
+type CSRFConfig struct {
+	Mode           CSRFMode
+	TokenLookup    string
+	CookieName     string
+	CookiePath     string
+	CookieDomain   string
+	CookieMaxAge   time.Duration
+	InsecureCookie bool
+	SessionKey     string
+	TrustedOrigins []string
+	ExemptRoutes   []string
+	Skip           func(z *Z) bool
+	ErrorHandler   func(z *Z, err error)
+}
+
+func (z *Z) CSRFToken() string {
+	token, _ := z.r.Context().Value(csrfContextKey).([]byte)
+	if token == nil {
+		return ""
+	}
+	return maskCSRFToken(token)
+}
+
+func (middlewaresRegistry) CSRF(cfg CSRFConfig) MiddlewareFunc {
+	if cfg.TokenLookup == "" {
+		cfg.TokenLookup = "header:X-CSRF-Token,form:csrf_token,query:csrf_token"
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "_csrf"
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.CookieMaxAge == 0 {
+		cfg.CookieMaxAge = 12 * time.Hour
+	}
+	if cfg.SessionKey == "" {
+		cfg.SessionKey = "_csrf_token"
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(z *Z, err error) {
+			z.String(http.StatusForbidden, "Forbidden")
+		}
+	}
+	extract := credentialExtractor(cfg.TokenLookup)
+	exempt := map[string]bool{}
+	for _, route := range cfg.ExemptRoutes {
+		exempt[route] = true
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			token, err := csrfSecret(z, cfg)
+			if err != nil {
+				slog.Error("Failed to load CSRF token", "err", err)
+				cfg.ErrorHandler(z, err)
+				return
+			}
+			z.setContextValue(csrfContextKey, token)
+
+			if isSafeMethod(z.r.Method) || exempt[z.RoutePattern()] || exempt[z.r.Method+" "+z.RoutePattern()] || (cfg.Skip != nil && cfg.Skip(z)) {
+				next(z)
+				return
+			}
+
+			if !csrfOriginAllowed(z, cfg.TrustedOrigins) {
+				cfg.ErrorHandler(z, ErrCSRFOrigin)
+				return
+			}
+
+			submitted := extract(z)
+			if submitted == "" {
+				cfg.ErrorHandler(z, ErrCSRFTokenMissing)
+				return
+			}
+			if !secureCompare(string(unmaskCSRFToken(submitted)), string(token)) {
+				cfg.ErrorHandler(z, ErrCSRFTokenInvalid)
+				return
+			}
+			next(z)
+		}
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func csrfSecret(z *Z, cfg CSRFConfig) ([]byte, error) {
+	if cfg.Mode == CSRFSynchronizer {
+		session := z.Session()
+		if session == nil {
+			return nil, errors.New("csrf synchronizer mode requires the session middleware")
+		}
+		if encoded, ok := session.Get(cfg.SessionKey).(string); ok {
+			if token, err := base64.RawURLEncoding.DecodeString(encoded); err == nil && len(token) == csrfTokenLength {
+				return token, nil
+			}
+		}
+		token := generateCSRFToken()
+		session.Set(cfg.SessionKey, base64.RawURLEncoding.EncodeToString(token))
+		return token, nil
+	}
+
+	if cookie, err := z.r.Cookie(cfg.CookieName); err == nil {
+		if token, err := base64.RawURLEncoding.DecodeString(cookie.Value); err == nil && len(token) == csrfTokenLength {
+			return token, nil
+		}
+	}
+	token := generateCSRFToken()
+	http.SetCookie(z.rw, &http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(token),
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(cfg.CookieMaxAge.Seconds()),
+		Secure:   !cfg.InsecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	z.rw.Header().Add("Vary", "Cookie")
+	return token, nil
+}
+
+func generateCSRFToken() []byte {
+	token := make([]byte, csrfTokenLength)
+	rand.Read(token)
+	return token
+}
+
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*len(token))
+	rand.Read(masked[:len(token)])
+	for i, b := range token {
+		masked[len(token)+i] = masked[i] ^ b
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func unmaskCSRFToken(value string) []byte {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	switch len(data) {
+	case csrfTokenLength:
+		return data
+	case 2 * csrfTokenLength:
+		token := make([]byte, csrfTokenLength)
+		for i := range token {
+			token[i] = data[i] ^ data[csrfTokenLength+i]
+		}
+		return token
+	}
+	return nil
+}
+
+func csrfOriginAllowed(z *Z, trusted []string) bool {
+	origin := z.r.Header.Get("Origin")
+	site := z.r.Header.Get("Sec-Fetch-Site")
+
+	if origin == "" || origin == "null" {
+		return site == "" || site == "same-origin" || site == "none"
+	}
+	if origin == z.Scheme()+"://"+z.Host() {
+		return true
+	}
+	for _, pattern := range trusted {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package z
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF_DoubleSubmit(t *testing.T) {
+	var gotErr error
+	mw := Middlewares.CSRF(CSRFConfig{
+		ErrorHandler: func(z *Z, err error) {
+			gotErr = err
+			z.String(http.StatusForbidden, "Forbidden")
+		},
+	})
+
+	var token string
+	handler := mw(func(z *Z) { token = z.CSRFToken() })
+
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: httptest.NewRequest("GET", "/form", nil)})
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "_csrf" || cookies[0].HttpOnly {
+		t.Fatalf("Expected readable _csrf cookie, got %+v", cookies)
+	}
+	if token == "" || token == cookies[0].Value {
+		t.Fatal("Expected a masked token")
+	}
+	cookie := cookies[0]
+
+	cases := []struct {
+		name    string
+		prepare func(r *http.Request)
+		wantErr error
+	}{
+		{"header", func(r *http.Request) { r.Header.Set("X-CSRF-Token", token) }, nil},
+		{"raw cookie value", func(r *http.Request) { r.Header.Set("X-CSRF-Token", cookie.Value) }, nil},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "csrf_token=" + url.QueryEscape(token) }, nil},
+		{"missing", func(r *http.Request) {}, ErrCSRFTokenMissing},
+		{"wrong", func(r *http.Request) { r.Header.Set("X-CSRF-Token", "bogus") }, ErrCSRFTokenInvalid},
+		{"cross origin", func(r *http.Request) {
+			r.Header.Set("X-CSRF-Token", token)
+			r.Header.Set("Origin", "https://evil.example")
+		}, ErrCSRFOrigin},
+		{"cross site fetch", func(r *http.Request) {
+			r.Header.Set("X-CSRF-Token", token)
+			r.Header.Set("Sec-Fetch-Site", "cross-site")
+		}, ErrCSRFOrigin},
+		{"same origin", func(r *http.Request) {
+			r.Header.Set("X-CSRF-Token", token)
+			r.Header.Set("Origin", "http://example.com")
+			r.Header.Set("Sec-Fetch-Site", "same-origin")
+		}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gotErr = nil
+			req := httptest.NewRequest("POST", "/submit", nil)
+			req.AddCookie(cookie)
+			c.prepare(req)
+			rr := httptest.NewRecorder()
+			handler(&Z{rw: rr, r: req})
+
+			if !errors.Is(gotErr, c.wantErr) {
+				t.Fatalf("Expected %v, got %v", c.wantErr, gotErr)
+			}
+		})
+	}
+}
+
+func TestCSRF_FormField(t *testing.T) {
+	mw := Middlewares.CSRF(CSRFConfig{})
+	var token string
+	rr := httptest.NewRecorder()
+	mw(func(z *Z) { token = z.CSRFToken() })(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+
+	body := strings.NewReader(url.Values{"csrf_token": {token}}.Encode())
+	req := httptest.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(rr.Result().Cookies()[0])
+	rr = httptest.NewRecorder()
+	mw(func(z *Z) { z.String(http.StatusOK, "ok") })(&Z{rw: rr, r: req})
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+}
+
+func TestCSRF_Synchronizer(t *testing.T) {
+	store := NewMemorySessionStore()
+	csrf := Middlewares.CSRF(CSRFConfig{Mode: CSRFSynchronizer})
+	session := Middlewares.Session(store)
+
+	var token string
+	rr := httptest.NewRecorder()
+	session(csrf(func(z *Z) { token = z.CSRFToken() }))(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Fatalf("Expected only a session cookie, got %+v", cookies)
+	}
+
+	for _, c := range []struct {
+		token string
+		want  int
+	}{
+		{token, http.StatusOK},
+		{"", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.AddCookie(cookies[0])
+		req.Header.Set("X-CSRF-Token", c.token)
+		rr := httptest.NewRecorder()
+		session(csrf(func(z *Z) { z.String(http.StatusOK, "ok") }))(&Z{rw: rr, r: req})
+		if rr.Code != c.want {
+			t.Fatalf("Expected %d, got %d", c.want, rr.Code)
+		}
+	}
+}
+
+func TestCSRF_Exemptions(t *testing.T) {
+	mw := Middlewares.CSRF(CSRFConfig{ExemptRoutes: []string{"POST /webhooks"}})
+
+	for _, c := range []struct {
+		method, pattern string
+		want            int
+	}{
+		{"POST", "/webhooks", http.StatusOK},
+		{"POST", "/orders", http.StatusForbidden},
+		{"HEAD", "/orders", http.StatusOK},
+	} {
+		rr := httptest.NewRecorder()
+		mw(func(z *Z) { z.String(http.StatusOK, "ok") })(&Z{rw: rr, r: httptest.NewRequest(c.method, c.pattern, nil), route: &Route{Pattern: c.pattern}})
+		if rr.Code != c.want {
+			t.Fatalf("%s %s: expected %d, got %d", c.method, c.pattern, c.want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package z
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const DefaultDecompressMaxSize = 10 << 20
+
+type DecompressConfig struct {
+	MaxSize int64
+}
+
+var gzipReaderPool sync.Pool
+
+func (middlewaresRegistry) Decompress() MiddlewareFunc {
+	return Middlewares.DecompressWithCfg(DecompressConfig{})
+}
+
+func (middlewaresRegistry) DecompressWithCfg(cfg DecompressConfig) MiddlewareFunc {
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultDecompressMaxSize
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			header := z.r.Header.Get("Content-Encoding")
+			if header == "" || z.r.Body == nil || z.r.Body == http.NoBody {
+				next(z)
+				return
+			}
+
+			encodings := strings.Split(header, ",")
+			for _, encoding := range encodings {
+				switch strings.ToLower(strings.TrimSpace(encoding)) {
+				case "gzip", "x-gzip", "deflate", "identity":
+				default:
+					z.rw.Header().Set("Accept-Encoding", "gzip, deflate")
+					z.String(http.StatusUnsupportedMediaType, "Unsupported Media Type")
+					return
+				}
+			}
+
+			body := io.Reader(z.r.Body)
+			var closers []func()
+			defer func() {
+				for _, c := range closers {
+					c()
+				}
+			}()
+			for i := len(encodings) - 1; i >= 0; i-- {
+				switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+				case "gzip", "x-gzip":
+					gz, _ := gzipReaderPool.Get().(*gzip.Reader)
+					var err error
+					if gz == nil {
+						gz, err = gzip.NewReader(body)
+					} else {
+						err = gz.Reset(body)
+					}
+					if err != nil {
+						z.String(http.StatusBadRequest, "Bad Request")
+						return
+					}
+					closers = append(closers, func() { gzipReaderPool.Put(gz) })
+					body = gz
+				case "deflate":
+					reader, err := deflateReader(body)
+					if err != nil {
+						z.String(http.StatusBadRequest, "Bad Request")
+						return
+					}
+					closers = append(closers, func() { reader.Close() })
+					body = reader
+				}
+			}
+
+			z.r.Body = http.MaxBytesReader(z.rw, readCloser{Reader: body, Closer: z.r.Body}, cfg.MaxSize)
+			z.r.Header.Del("Content-Encoding")
+			z.r.Header.Del("Content-Length")
+			z.r.ContentLength = -1
+			next(z)
+		}
+	}
+}
+
+func deflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,89 @@
+package z
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func compressBody(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	w.Write([]byte(body))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressMiddleware(t *testing.T) {
+	payload := `{"name":"` + strings.Repeat("a", 500) + `"}`
+	cases := []struct {
+		name     string
+		encoding string
+		body     []byte
+		wantCode int
+	}{
+		{"gzip", "gzip", compressBody(t, "gzip", payload), http.StatusOK},
+		{"deflate zlib", "deflate", compressBody(t, "zlib", payload), http.StatusOK},
+		{"deflate raw", "deflate", compressBody(t, "flate", payload), http.StatusOK},
+		{"identity", "", []byte(payload), http.StatusOK},
+		{"unsupported", "br", []byte(payload), http.StatusUnsupportedMediaType},
+		{"corrupt gzip", "gzip", []byte("not gzip"), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(c.body))
+			if c.encoding != "" {
+				req.Header.Set("Content-Encoding", c.encoding)
+			}
+			rr := httptest.NewRecorder()
+			Middlewares.Decompress()(func(z *Z) {
+				var p struct{ Name string }
+				if err := z.BindBody(&p); err != nil || len(p.Name) != 500 {
+					t.Fatalf("Bind failed: %v", err)
+				}
+				if z.Request().Header.Get("Content-Encoding") != "" {
+					t.Fatal("Expected Content-Encoding to be removed")
+				}
+				z.Ok("ok")
+			})(&Z{rw: rr, r: req})
+
+			if rr.Code != c.wantCode {
+				t.Fatalf("Expected %d, got %d", c.wantCode, rr.Code)
+			}
+			if c.wantCode == http.StatusUnsupportedMediaType && rr.Header().Get("Accept-Encoding") == "" {
+				t.Fatal("Expected Accept-Encoding on 415")
+			}
+		})
+	}
+}
+
+func TestDecompressMiddleware_MaxSize(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(compressBody(t, "gzip", strings.Repeat("0", 1<<20))))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	var readErr error
+	Middlewares.DecompressWithCfg(DecompressConfig{MaxSize: 1024})(func(z *Z) {
+		_, readErr = io.ReadAll(z.Request().Body)
+	})(&Z{rw: httptest.NewRecorder(), r: req})
+
+	var maxErr *http.MaxBytesError
+	if !errors.As(readErr, &maxErr) {
+		t.Fatalf("Expected MaxBytesError, got %v", readErr)
+	}
+}
@@ -0,0 +1,231 @@
+package z
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ETagConfig struct {
+	Weak               bool
+	RequireConditional bool
+}
+
+func FormatETag(tag string, weak bool) string {
+	tag = strconv.Quote(strings.Trim(tag, `"`))
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+func (z *Z) SetETag(tag string, weak bool) {
+	z.rw.Header().Set("ETag", FormatETag(tag, weak))
+}
+
+func (z *Z) SetLastModified(t time.Time) {
+	z.rw.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+func (z *Z) CheckPreconditions(etag string, lastModified time.Time) bool {
+	if etag != "" && !strings.HasSuffix(etag, `"`) {
+		etag = FormatETag(etag, false)
+	}
+	switch status := evaluatePreconditions(z.r, etag, lastModified); status {
+	case http.StatusNotModified:
+		if etag != "" {
+			z.rw.Header().Set("ETag", etag)
+		}
+		z.rw.WriteHeader(status)
+		return false
+	case http.StatusPreconditionFailed:
+		z.String(status, "Precondition Failed")
+		return false
+	}
+	return true
+}
+
+func (middlewaresRegistry) ETag() MiddlewareFunc {
+	return Middlewares.ETagWithCfg(ETagConfig{})
+}
+
+func (middlewaresRegistry) ETagWithCfg(cfg ETagConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			method := z.r.Method
+			if method != http.MethodGet && method != http.MethodHead {
+				if cfg.RequireConditional && (method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete) &&
+					z.r.Header.Get("If-Match") == "" && z.r.Header.Get("If-Unmodified-Since") == "" {
+					z.String(http.StatusPreconditionRequired, "Precondition Required")
+					return
+				}
+				next(z)
+				return
+			}
+
+			rw := z.rw
+			writer := &etagWriter{ResponseWriter: rw, status: http.StatusOK}
+			z.rw = writer
+			defer func() { z.rw = rw }()
+			next(z)
+
+			if writer.streaming {
+				return
+			}
+			writer.finish(z.r, cfg)
+		}
+	}
+}
+
+type etagWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	streaming   bool
+}
+
+func (ew *etagWriter) WriteHeader(statusCode int) {
+	if ew.streaming {
+		ew.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if !ew.wroteHeader {
+		ew.status = statusCode
+		ew.wroteHeader = true
+	}
+}
+
+func (ew *etagWriter) Write(b []byte) (int, error) {
+	if ew.streaming {
+		return ew.ResponseWriter.Write(b)
+	}
+	ew.wroteHeader = true
+	return ew.buf.Write(b)
+}
+
+func (ew *etagWriter) Flush() {
+	if !ew.streaming {
+		ew.streaming = true
+		ew.ResponseWriter.WriteHeader(ew.status)
+		ew.ResponseWriter.Write(ew.buf.Bytes())
+		ew.buf.Reset()
+	}
+	http.NewResponseController(ew.ResponseWriter).Flush()
+}
+
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+func (ew *etagWriter) finish(r *http.Request, cfg ETagConfig) {
+	header := ew.ResponseWriter.Header()
+	if ew.status == http.StatusOK {
+		etag := header.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(ew.buf.Bytes())
+			etag = FormatETag(hex.EncodeToString(sum[:16]), cfg.Weak)
+			header.Set("ETag", etag)
+		}
+		lastModified, _ := http.ParseTime(header.Get("Last-Modified"))
+
+		switch status := evaluatePreconditions(r, etag, lastModified); status {
+		case http.StatusNotModified:
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			ew.ResponseWriter.WriteHeader(status)
+			return
+		case http.StatusPreconditionFailed:
+			header.Del("ETag")
+			header.Del("Last-Modified")
+			http.Error(ew.ResponseWriter, "Precondition Failed", status)
+			return
+		}
+	}
+
+	if !ew.wroteHeader && ew.buf.Len() == 0 {
+		return
+	}
+	ew.ResponseWriter.WriteHeader(ew.status)
+	ew.ResponseWriter.Write(ew.buf.Bytes())
+}
+
+func evaluatePreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	exists := etag != "" || !lastModified.IsZero()
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, exists, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, exists, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+func matchETag(header, etag string, exists, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return exists
+	}
+	if etag == "" {
+		return false
+	}
+	current, currentWeak := parseETag(etag)
+	if strong && currentWeak {
+		return false
+	}
+	for _, candidate := range splitETags(header) {
+		tag, weak := parseETag(candidate)
+		if strong && weak {
+			continue
+		}
+		if tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+func parseETag(etag string) (string, bool) {
+	etag = strings.TrimSpace(etag)
+	weak := strings.HasPrefix(etag, "W/")
+	return strings.TrimPrefix(etag, "W/"), weak
+}
+
+func splitETags(header string) []string {
+	var tags []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(header); i++ {
+		switch header[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				tags = append(tags, header[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(tags, header[start:])
+}
@@ -0,0 +1,138 @@
+package z
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestETagMiddleware(t *testing.T) {
+	handler := Middlewares.ETag()(func(z *Z) { z.OkJSON(map[string]string{"hello": "world"}) })
+
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) || rr.Body.Len() == 0 {
+		t.Fatalf("Unexpected response: %d %q %q", rr.Code, etag, rr.Body.String())
+	}
+
+	cases := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"if-none-match hit", "If-None-Match", etag, http.StatusNotModified},
+		{"if-none-match weak hit", "If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+		{"if-none-match star", "If-None-Match", "*", http.StatusNotModified},
+		{"if-none-match miss", "If-None-Match", `"other"`, http.StatusOK},
+		{"if-match hit", "If-Match", etag, http.StatusOK},
+		{"if-match weak", "If-Match", "W/" + etag, http.StatusPreconditionFailed},
+		{"if-match miss", "If-Match", `"other"`, http.StatusPreconditionFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(c.header, c.value)
+			rr := httptest.NewRecorder()
+			handler(&Z{rw: rr, r: req})
+
+			if rr.Code != c.wantCode {
+				t.Fatalf("Expected %d, got %d", c.wantCode, rr.Code)
+			}
+			if c.wantCode == http.StatusNotModified && (rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag) {
+				t.Fatalf("Expected empty 304 with ETag, got %q %q", rr.Body.String(), rr.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestETagMiddleware_HandlerProvided(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	handler := Middlewares.ETagWithCfg(ETagConfig{Weak: true})(func(z *Z) {
+		z.SetETag("v42", true)
+		z.SetLastModified(modified)
+		z.Ok("body")
+	})
+
+	cases := []struct {
+		header, value string
+		wantCode      int
+	}{
+		{"If-None-Match", `W/"v42"`, http.StatusNotModified},
+		{"If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+		{"If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusPreconditionFailed},
+		{"If-Unmodified-Since", modified.Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(c.header, c.value)
+		rr := httptest.NewRecorder()
+		handler(&Z{rw: rr, r: req})
+		if rr.Code != c.wantCode {
+			t.Fatalf("%s %s: expected %d, got %d", c.header, c.value, c.wantCode, rr.Code)
+		}
+	}
+}
+
+func TestCheckPreconditions_OptimisticConcurrency(t *testing.T) {
+	version := "3"
+	handler := Middlewares.ETagWithCfg(ETagConfig{RequireConditional: true})(func(z *Z) {
+		if !z.CheckPreconditions(version, time.Time{}) {
+			return
+		}
+		version = "4"
+		z.SetETag(version, false)
+		z.Ok("updated")
+	})
+
+	cases := []struct {
+		ifMatch  string
+		wantCode int
+	}{
+		{"", http.StatusPreconditionRequired},
+		{`"2"`, http.StatusPreconditionFailed},
+		{`"3"`, http.StatusOK},
+		{`"3"`, http.StatusPreconditionFailed},
+		{"*", http.StatusOK},
+	}
+	for i, c := range cases {
+		req := httptest.NewRequest("PUT", "/", nil)
+		if c.ifMatch != "" {
+			req.Header.Set("If-Match", c.ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		handler(&Z{rw: rr, r: req})
+		if rr.Code != c.wantCode {
+			t.Fatalf("Case %d: expected %d, got %d", i, c.wantCode, rr.Code)
+		}
+	}
+}
+
+func TestETagMiddleware_SkipsErrors(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", "*")
+	rr := httptest.NewRecorder()
+	Middlewares.ETag()(func(z *Z) { z.String(http.StatusNotFound, "missing") })(&Z{rw: rr, r: req})
+
+	if rr.Code != http.StatusNotFound || rr.Header().Get("ETag") != "" || rr.Body.String() != "missing" {
+		t.Fatalf("Unexpected response: %d %v %q", rr.Code, rr.Header(), rr.Body.String())
+	}
+}
+
+func TestETagMiddleware_Recovery(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.RecoveryWithCfg(RecoveryConfig{JSON: true}))
+	app.Use(Middlewares.ETag())
+	app.GET("/", func(z *Z) { panic("boom") })
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "Internal Server Error") {
+		t.Fatalf("Expected 500 from Recovery, got %d %q", rr.Code, rr.Body.String())
+	}
+}
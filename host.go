@@ -0,0 +1,51 @@
+package z
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+func requestHost(app *App, r *http.Request) string {
+	host := (&Z{r: r, app: app}).Host()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func hostShape(pattern string) string {
+	labels := strings.Split(pattern, ".")
+	for i, label := range labels {
+		if strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") {
+			labels[i] = "{}"
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func matchHost(pattern, host string) (map[string]string, bool) {
+	patternLabels := strings.Split(pattern, ".")
+	hostLabels := strings.Split(host, ".")
+	if len(patternLabels) != len(hostLabels) {
+		return nil, false
+	}
+
+	var values map[string]string
+	for i, label := range patternLabels {
+		if strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") {
+			if hostLabels[i] == "" {
+				return nil, false
+			}
+			if values == nil {
+				values = map[string]string{}
+			}
+			values[label[1:len(label)-1]] = hostLabels[i]
+			continue
+		}
+		if label != hostLabels[i] {
+			return nil, false
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,94 @@
+package z
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRouting(t *testing.T) {
+	app := New()
+	app.Host("api.example.com").GET("/status", func(z *Z) { z.Ok("api") })
+	app.Host("Admin.Example.com").Group("/panel").GET("/users/{id}", func(z *Z) { z.Ok("admin:" + z.PathValue("id")) })
+	app.Host("{tenant}.example.com").GET("/status", func(z *Z) { z.Ok("tenant:" + z.PathValue("tenant")) })
+	app.Host("{tenant}.{region}.example.com").GET("/status", func(z *Z) {
+		z.Ok(z.PathValue("tenant") + "@" + z.PathValue("region"))
+	})
+	app.GET("/status", func(z *Z) { z.Ok("default") })
+
+	cases := []struct {
+		host, target string
+		wantCode     int
+		wantBody     string
+	}{
+		{"api.example.com", "/status", http.StatusOK, "api"},
+		{"api.example.com:8080", "/status", http.StatusOK, "api"},
+		{"acme.example.com", "/status", http.StatusOK, "tenant:acme"},
+		{"acme.eu.example.com", "/status", http.StatusOK, "acme@eu"},
+		{"example.com", "/status", http.StatusOK, "default"},
+		{"localhost", "/status", http.StatusOK, "default"},
+		{"admin.example.com", "/panel/users/7", http.StatusOK, "admin:7"},
+		{"api.example.com", "/panel/users/7", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.target, nil)
+		req.Host = c.host
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, req)
+		if rr.Code != c.wantCode || (c.wantBody != "" && rr.Body.String() != c.wantBody) {
+			t.Errorf("%s%s: expected %d %q, got %d %q", c.host, c.target, c.wantCode, c.wantBody, rr.Code, rr.Body.String())
+		}
+	}
+	if err := app.Err(); err != nil {
+		t.Fatalf("Expected host routes not to conflict, got %v", err)
+	}
+	if routes := app.Routes(); routes[1].Host != "admin.example.com" || routes[1].Pattern != "/panel/users/{id}" {
+		t.Fatalf("Unexpected route: %+v", routes[1])
+	}
+
+	app.Host("api.example.com").GET("/status", func(z *Z) {})
+	if app.Err() == nil {
+		t.Fatal("Expected duplicate host route to be reported")
+	}
+}
+
+func TestHostRouting_LiteralBeforeWildcard(t *testing.T) {
+	app := New()
+	app.Host("{tenant}.example.com").GET("/status", func(z *Z) { z.Ok("tenant:" + z.PathValue("tenant")) })
+	app.GET("/status", func(z *Z) { z.Ok("default") })
+	app.Host("api.example.com").GET("/status", func(z *Z) { z.Ok("api") })
+
+	for host, want := range map[string]string{"api.example.com": "api", "acme.example.com": "tenant:acme", "localhost": "default"} {
+		req := httptest.NewRequest("GET", "/status", nil)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, req)
+		if rr.Body.String() != want {
+			t.Errorf("%s: expected %q, got %q", host, want, rr.Body.String())
+		}
+	}
+
+	app.Host("{other}.example.com").GET("/status", func(z *Z) {})
+	if err := app.Err(); !errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("Expected wildcard hosts of the same shape to conflict, got %v", err)
+	}
+}
+
+func TestHostRouting_TrustedProxy(t *testing.T) {
+	app := New()
+	app.SetTrustedProxies("10.0.0.0/8")
+	app.Host("{tenant}.example.com").GET("/", func(z *Z) { z.Ok(z.PathValue("tenant")) })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "internal:8080"
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	req.Header.Set("X-Forwarded-Host", "globex.example.com")
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, req)
+
+	if rr.Body.String() != "globex" {
+		t.Fatalf("Expected forwarded host to be used, got %d %q", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,54 @@
+package z
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestUUIDv4(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := UUIDv4()
+		if !re.MatchString(id) {
+			t.Fatalf("UUIDv4 %q has an invalid format", id)
+		}
+		if seen[id] {
+			t.Fatalf("UUIDv4 generated a duplicate: %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestUUIDv7(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first := UUIDv7()
+	if !re.MatchString(first) {
+		t.Fatalf("UUIDv7 %q has an invalid format", first)
+	}
+	if second := UUIDv7(); second[:8] < first[:8] {
+		t.Errorf("UUIDv7 should be time ordered, got %q after %q", second, first)
+	}
+}
+
+func TestULID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	id := ULID()
+	if !re.MatchString(id) {
+		t.Fatalf("ULID %q has an invalid format", id)
+	}
+	if ULID() == id {
+		t.Error("ULID generated a duplicate")
+	}
+}
+
+func TestKSUID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9A-Za-z]{27}$`)
+	id := KSUID()
+	if !re.MatchString(id) {
+		t.Fatalf("KSUID %q has an invalid format", id)
+	}
+	if KSUID() == id {
+		t.Error("KSUID generated a duplicate")
+	}
+}
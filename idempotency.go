@@ -0,0 +1,230 @@
+package z
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DefaultIdempotencyMaxBodyBytes = 10 << 20
+
+type IdempotencyRecord struct {
+	Fingerprint string
+	Completed   bool
+	Status      int
+	Header      http.Header
+	Body        []byte
+	Expires     time.Time
+}
+
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (existing *IdempotencyRecord, reserved bool, err error)
+	Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+type IdempotencyConfig struct {
+	Store        IdempotencyStore
+	TTL          time.Duration
+	HeaderName   string
+	Methods      []string
+	Required     bool
+	MaxBodyBytes int64
+	Scope        func(z *Z) string
+}
+
+func (middlewaresRegistry) Idempotency() MiddlewareFunc {
+	return Middlewares.IdempotencyWithCfg(IdempotencyConfig{})
+}
+
+func (middlewaresRegistry) IdempotencyWithCfg(cfg IdempotencyConfig) MiddlewareFunc {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryIdempotencyStore()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "Idempotency-Key"
+	}
+	if cfg.Methods == nil {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = DefaultIdempotencyMaxBodyBytes
+	}
+	if cfg.Scope == nil {
+		cfg.Scope = func(z *Z) string {
+			scope := z.r.Method + " " + z.r.URL.Path
+			if principal := z.Principal(); principal != nil {
+				scope += " " + principal.Subject
+			}
+			return scope
+		}
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			if !slices.Contains(cfg.Methods, z.r.Method) {
+				next(z)
+				return
+			}
+
+			key := z.r.Header.Get(cfg.HeaderName)
+			if key == "" {
+				if cfg.Required {
+					z.String(http.StatusBadRequest, "Missing "+cfg.HeaderName+" header")
+					return
+				}
+				next(z)
+				return
+			}
+			if len(key) > 255 {
+				z.String(http.StatusBadRequest, "Invalid "+cfg.HeaderName+" header")
+				return
+			}
+
+			var body []byte
+			if z.r.Body != nil {
+				var err error
+				body, err = io.ReadAll(http.MaxBytesReader(z.rw, z.r.Body, cfg.MaxBodyBytes))
+				if err != nil {
+					var maxErr *http.MaxBytesError
+					if errors.As(err, &maxErr) {
+						z.String(http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+						return
+					}
+					z.String(http.StatusBadRequest, "Bad Request")
+					return
+				}
+				z.r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			fingerprint := idempotencyFingerprint(z.r, body)
+
+			storeKey := cfg.Scope(z) + " " + key
+			ctx := z.r.Context()
+			existing, reserved, err := cfg.Store.Reserve(ctx, storeKey, fingerprint, cfg.TTL)
+			if err != nil {
+				slog.Error("Idempotency store failed", "err", err)
+				z.String(http.StatusServiceUnavailable, "Service Unavailable")
+				return
+			}
+
+			if !reserved {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					z.String(http.StatusUnprocessableEntity, cfg.HeaderName+" was used with a different request")
+				case !existing.Completed:
+					z.rw.Header().Set("Retry-After", "1")
+					z.String(http.StatusConflict, "A request with this "+cfg.HeaderName+" is in progress")
+				default:
+					header := z.rw.Header()
+					for name, values := range existing.Header {
+						header[name] = slices.Clone(values)
+					}
+					header.Set("Idempotent-Replayed", "true")
+					z.rw.WriteHeader(existing.Status)
+					z.rw.Write(existing.Body)
+				}
+				return
+			}
+
+			completed := false
+			defer func() {
+				if !completed {
+					if err := cfg.Store.Release(context.WithoutCancel(ctx), storeKey); err != nil {
+						slog.Error("Idempotency store failed", "err", err)
+					}
+				}
+			}()
+
+			writer := &cacheWriter{ResponseWriter: z.rw, status: http.StatusOK}
+			z.rw = writer
+			next(z)
+
+			if writer.status >= 500 || writer.streaming {
+				return
+			}
+			record := &IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Completed:   true,
+				Status:      writer.status,
+				Header:      writer.Header().Clone(),
+				Body:        writer.body.Bytes(),
+			}
+			record.Header.Del("Set-Cookie")
+			if err := cfg.Store.Complete(context.WithoutCancel(ctx), storeKey, record, cfg.TTL); err != nil {
+				slog.Error("Idempotency store failed", "err", err)
+				return
+			}
+			completed = true
+		}
+	}
+}
+
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write([]byte(r.Header.Get("Content-Type") + "\n"))
+	h.Write([]byte(strconv.Itoa(len(body)) + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+const memoryIdempotencySweepInterval = time.Minute
+
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]*IdempotencyRecord
+	now       func() time.Time
+	nextSweep time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: map[string]*IdempotencyRecord{}, now: time.Now}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.After(s.nextSweep) {
+		for k, record := range s.records {
+			if now.After(record.Expires) {
+				delete(s.records, k)
+			}
+		}
+		s.nextSweep = now.Add(memoryIdempotencySweepInterval)
+	}
+
+	if record, ok := s.records[key]; ok && !now.After(record.Expires) {
+		return record, false, nil
+	}
+	s.records[key] = &IdempotencyRecord{Fingerprint: fingerprint, Expires: now.Add(ttl)}
+	return nil, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record.Expires = s.now().Add(ttl)
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
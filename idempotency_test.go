@@ -0,0 +1,149 @@
+package z
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func idempotentRequest(handler HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/payments", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+	return rr
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	handler := Middlewares.Idempotency()(func(z *Z) {
+		calls.Add(1)
+		var p struct{ Amount int }
+		z.BindBody(&p)
+		z.SetHeader("X-Payment", "pay_1")
+		z.JSON(http.StatusCreated, p)
+	})
+
+	first := idempotentRequest(handler, "k1", `{"amount":10}`)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("Unexpected first response: %d %v", first.Code, first.Header())
+	}
+
+	retry := idempotentRequest(handler, "k1", `{"amount":10}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get("X-Payment") != "pay_1" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("Expected replay, got %d %v %q", retry.Code, retry.Header(), retry.Body.String())
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected handler to run once, ran %d times", calls.Load())
+	}
+
+	if rr := idempotentRequest(handler, "k1", `{"amount":20}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422 for different payload, got %d", rr.Code)
+	}
+	if rr := idempotentRequest(handler, "", `{"amount":10}`); rr.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Fatalf("Expected request without key to pass through, got %d", rr.Code)
+	}
+}
+
+func TestIdempotencyMiddleware_InFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Middlewares.Idempotency()(func(z *Z) {
+		close(started)
+		<-release
+		z.Ok("done")
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- idempotentRequest(handler, "k", "{}") }()
+	<-started
+
+	rr := idempotentRequest(handler, "k", "{}")
+	if rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected 409 for in-flight duplicate, got %d", rr.Code)
+	}
+	close(release)
+	if rr := <-done; rr.Code != http.StatusOK {
+		t.Fatalf("Expected original request to succeed, got %d", rr.Code)
+	}
+}
+
+func TestIdempotencyMiddleware_ServerErrorsAllowRetry(t *testing.T) {
+	var calls int
+	handler := Middlewares.Idempotency()(func(z *Z) {
+		calls++
+		if calls == 1 {
+			z.String(http.StatusBadGateway, "upstream down")
+			return
+		}
+		if calls == 2 {
+			panic("boom")
+		}
+		z.Ok("ok")
+	})
+
+	idempotentRequest(handler, "k", "{}")
+	func() {
+		defer func() { recover() }()
+		idempotentRequest(handler, "k", "{}")
+	}()
+	if rr := idempotentRequest(handler, "k", "{}"); rr.Code != http.StatusOK || calls != 3 {
+		t.Fatalf("Expected retry after failures to run the handler, got %d after %d calls", rr.Code, calls)
+	}
+}
+
+func TestIdempotencyMiddleware_Required(t *testing.T) {
+	handler := Middlewares.IdempotencyWithCfg(IdempotencyConfig{Required: true})(func(z *Z) { z.Ok("ok") })
+	if rr := idempotentRequest(handler, "", "{}"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 without key, got %d", rr.Code)
+	}
+}
+
+func TestMemoryIdempotencyStore_Expiry(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemoryIdempotencyStore()
+	store.now = clock.Now
+
+	if _, reserved, _ := store.Reserve(context.Background(), "k", "fp", time.Minute); !reserved {
+		t.Fatal("Expected first reservation to succeed")
+	}
+	if _, reserved, _ := store.Reserve(context.Background(), "k", "fp", time.Minute); reserved {
+		t.Fatal("Expected duplicate reservation to fail")
+	}
+	clock.Advance(2 * time.Minute)
+	if _, reserved, _ := store.Reserve(context.Background(), "k", "fp", time.Minute); !reserved {
+		t.Fatal("Expected reservation after expiry to succeed")
+	}
+}
+
+func TestMemoryIdempotencyStore_SweepsPeriodically(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemoryIdempotencyStore()
+	store.now = clock.Now
+
+	store.Reserve(context.Background(), "old", "fp", time.Second)
+	clock.Advance(2 * time.Second)
+	if _, reserved, _ := store.Reserve(context.Background(), "old", "fp", time.Hour); !reserved {
+		t.Fatal("Expected an expired key to be reservable before the next sweep")
+	}
+	store.Reserve(context.Background(), "stale", "fp", time.Second)
+	clock.Advance(2 * time.Second)
+	store.Reserve(context.Background(), "new", "fp", time.Hour)
+	if len(store.records) != 3 {
+		t.Fatalf("Expected no sweep before the interval, got %d records", len(store.records))
+	}
+
+	clock.Advance(memoryIdempotencySweepInterval)
+	store.Reserve(context.Background(), "newer", "fp", time.Hour)
+	if _, ok := store.records["stale"]; ok || len(store.records) != 3 {
+		t.Fatalf("Expected expired records to be swept, got %d records", len(store.records))
+	}
+}
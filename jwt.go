@@ -0,0 +1,483 @@
+package z
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrKeyNotFound  = errors.New("signing key not found")
+)
+
+type JWTKeyProvider interface {
+	Key(ctx context.Context, kid, alg string) (any, error)
+}
+
+type staticJWTKey struct {
+	key any
+}
+
+func StaticJWTKey(key any) JWTKeyProvider {
+	return staticJWTKey{key: key}
+}
+
+func (k staticJWTKey) Key(ctx context.Context, kid, alg string) (any, error) {
+	return k.key, nil
+}
+
+type JWTConfig struct {
+	Keys                   JWTKeyProvider
+	Algorithms             []string
+	Issuer                 string
+	Audience               string
+	ClockSkew              time.Duration
+	Lookup                 string
+	PrincipalFromJWT       func(claims map[string]any) (*Principal, error)
+	AllowMissingExpiration bool
+}
+
+func (middlewaresRegistry) JWT(keys JWTKeyProvider) MiddlewareFunc {
+	return Middlewares.JWTWithCfg(JWTConfig{Keys: keys})
+}
+
+func (middlewaresRegistry) JWTWithCfg(cfg JWTConfig) MiddlewareFunc {
+	if cfg.Lookup == "" {
+		cfg.Lookup = "header:Authorization:Bearer "
+	}
+	if cfg.Algorithms == nil {
+		cfg.Algorithms = []string{"HS256", "RS256", "ES256", "EdDSA"}
+	}
+	if cfg.PrincipalFromJWT == nil {
+		cfg.PrincipalFromJWT = principalFromClaims
+	}
+	extract := credentialExtractor(cfg.Lookup)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			token := extract(z)
+			if token == "" {
+				unauthorized(z, bearerChallenge(cfg.Lookup, ""))
+				return
+			}
+
+			claims, err := VerifyJWT(z.r.Context(), token, cfg)
+			if err != nil {
+				unauthorized(z, bearerChallenge(cfg.Lookup, "invalid_token"))
+				return
+			}
+			principal, err := cfg.PrincipalFromJWT(claims)
+			if err != nil || principal == nil {
+				unauthorized(z, bearerChallenge(cfg.Lookup, "invalid_token"))
+				return
+			}
+			z.SetPrincipal(principal)
+			next(z)
+		}
+	}
+}
+
+func VerifyJWT(ctx context.Context, token string, cfg JWTConfig) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !slices.Contains(cfg.Algorithms, header.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %q not allowed", ErrInvalidToken, header.Alg)
+	}
+	if cfg.Keys == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	key, err := cfg.Keys.Key(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := validateJWTClaims(claims, cfg, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeJWTSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func verifyJWTSignature(alg string, key any, signed, signature []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%w: HS256 requires a []byte secret", ErrInvalidToken)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidToken
+		}
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: RS256 requires an RSA public key", ErrInvalidToken)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidToken
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() || len(signature) != 64 {
+			return fmt.Errorf("%w: ES256 requires a P-256 public key", ErrInvalidToken)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: EdDSA requires an Ed25519 public key", ErrInvalidToken)
+		}
+		if !ed25519.Verify(pub, signed, signature) {
+			return ErrInvalidToken
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	return nil
+}
+
+func validateJWTClaims(claims map[string]any, cfg JWTConfig, now time.Time) error {
+	exp, hasExp := numericClaim(claims, "exp")
+	if hasExp && now.After(exp.Add(cfg.ClockSkew)) {
+		return ErrTokenExpired
+	}
+	if !hasExp && !cfg.AllowMissingExpiration {
+		return fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(cfg.ClockSkew).Before(nbf) {
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+	if cfg.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != cfg.Issuer {
+			return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+		}
+	}
+	if cfg.Audience != "" && !slices.Contains(stringsClaim(claims, "aud"), cfg.Audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+func numericClaim(claims map[string]any, name string) (time.Time, bool) {
+	n, ok := claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), true
+}
+
+func stringsClaim(claims map[string]any, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func principalFromClaims(claims map[string]any) (*Principal, error) {
+	subject, _ := claims["sub"].(string)
+	principal := &Principal{
+		Subject: subject,
+		Method:  "jwt",
+		Roles:   stringsClaim(claims, "roles"),
+		Claims:  claims,
+	}
+	if scope, ok := claims["scope"].(string); ok {
+		principal.Scopes = strings.Fields(scope)
+	} else {
+		principal.Scopes = stringsClaim(claims, "scp")
+	}
+	return principal, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func (k JWK) PublicKey() (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := decode(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symmetric key: %w", err)
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+type JWKS struct {
+	keys map[string]any
+	all  []any
+}
+
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	jwks := &JWKS{keys: map[string]any{}}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWK %q: %w", jwk.Kid, err)
+		}
+		if jwk.Kid != "" {
+			jwks.keys[jwk.Kid] = key
+		}
+		jwks.all = append(jwks.all, key)
+	}
+	return jwks, nil
+}
+
+func LoadJWKSFile(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+func (j *JWKS) Key(ctx context.Context, kid, alg string) (any, error) {
+	if kid != "" {
+		if key, ok := j.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, ErrKeyNotFound
+	}
+	if len(j.all) == 1 {
+		return j.all[0], nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+type RemoteJWKS struct {
+	url         string
+	client      *http.Client
+	ttl         time.Duration
+	minInterval time.Duration
+
+	mu         sync.Mutex
+	jwks       *JWKS
+	fetchedAt  time.Time
+	fetchErr   error
+	failedAt   time.Time
+	refreshing chan struct{}
+}
+
+func NewRemoteJWKS(url string, ttl time.Duration) *RemoteJWKS {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &RemoteJWKS{
+		url:         url,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		ttl:         ttl,
+		minInterval: time.Minute,
+	}
+}
+
+func (r *RemoteJWKS) Key(ctx context.Context, kid, alg string) (any, error) {
+	r.mu.Lock()
+	cached := r.jwks
+	if cached != nil && time.Since(r.fetchedAt) <= r.ttl {
+		key, err := cached.Key(ctx, kid, alg)
+		if err == nil || time.Since(r.fetchedAt) < r.minInterval {
+			r.mu.Unlock()
+			return key, err
+		}
+	}
+
+	if r.fetchErr != nil && time.Since(r.failedAt) < r.minInterval {
+		err := r.fetchErr
+		r.mu.Unlock()
+		if cached != nil {
+			return cached.Key(ctx, kid, alg)
+		}
+		return nil, err
+	}
+
+	if done := r.refreshing; done != nil {
+		r.mu.Unlock()
+		if cached != nil {
+			return cached.Key(ctx, kid, alg)
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		r.mu.Lock()
+		jwks, err := r.jwks, r.fetchErr
+		r.mu.Unlock()
+		if jwks == nil {
+			return nil, err
+		}
+		return jwks.Key(ctx, kid, alg)
+	}
+
+	done := make(chan struct{})
+	r.refreshing = done
+	r.mu.Unlock()
+
+	jwks, err := r.fetch(context.WithoutCancel(ctx))
+
+	r.mu.Lock()
+	if err == nil {
+		r.jwks = jwks
+		r.fetchedAt = time.Now()
+	} else {
+		r.failedAt = time.Now()
+	}
+	r.fetchErr = err
+	r.refreshing = nil
+	r.mu.Unlock()
+	close(done)
+
+	if err != nil {
+		if cached != nil {
+			return cached.Key(ctx, kid, alg)
+		}
+		return nil, err
+	}
+	return jwks.Key(ctx, kid, alg)
+}
+
+func (r *RemoteJWKS) fetch(ctx context.Context) (*JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return ParseJWKS(data)
+}
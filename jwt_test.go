@@ -0,0 +1,369 @@
+package z
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func signTestJWT(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case "EdDSA":
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(signed))
+	case "none":
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "user-1",
+		"iss":   "https://issuer.example.com",
+		"aud":   []string{"orders-api", "other"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "orders:read orders:write",
+		"roles": []string{"admin"},
+	}
+}
+
+func TestVerifyJWT_Algorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("super-secret")
+
+	cases := []struct {
+		alg     string
+		signKey any
+		pubKey  any
+	}{
+		{"HS256", secret, secret},
+		{"RS256", rsaKey, &rsaKey.PublicKey},
+		{"ES256", ecKey, &ecKey.PublicKey},
+		{"EdDSA", edPriv, edPub},
+	}
+	for _, c := range cases {
+		t.Run(c.alg, func(t *testing.T) {
+			token := signTestJWT(t, c.alg, "", c.signKey, validClaims())
+			cfg := JWTConfig{
+				Keys:       StaticJWTKey(c.pubKey),
+				Algorithms: []string{c.alg},
+				Issuer:     "https://issuer.example.com",
+				Audience:   "orders-api",
+			}
+			claims, err := VerifyJWT(context.Background(), token, cfg)
+			if err != nil {
+				t.Fatalf("VerifyJWT failed: %v", err)
+			}
+			if claims["sub"] != "user-1" {
+				t.Errorf("Unexpected claims %v", claims)
+			}
+
+			tampered := token[:len(token)-4] + "AAAA"
+			if _, err := VerifyJWT(context.Background(), tampered, cfg); err == nil {
+				t.Error("Expected tampered signature to be rejected")
+			}
+		})
+	}
+}
+
+func TestVerifyJWT_Rejections(t *testing.T) {
+	secret := []byte("super-secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	base := JWTConfig{Keys: StaticJWTKey(secret), Algorithms: []string{"HS256"}}
+
+	with := func(mutate func(c map[string]any)) map[string]any {
+		c := validClaims()
+		mutate(c)
+		return c
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		cfg   JWTConfig
+		want  error
+	}{
+		{"expired", signTestJWT(t, "HS256", "", secret, with(func(c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), base, ErrTokenExpired},
+		{"not yet valid", signTestJWT(t, "HS256", "", secret, with(func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() })), base, ErrInvalidToken},
+		{"wrong issuer", signTestJWT(t, "HS256", "", secret, validClaims()), JWTConfig{Keys: base.Keys, Algorithms: base.Algorithms, Issuer: "other"}, ErrInvalidToken},
+		{"wrong audience", signTestJWT(t, "HS256", "", secret, validClaims()), JWTConfig{Keys: base.Keys, Algorithms: base.Algorithms, Audience: "billing"}, ErrInvalidToken},
+		{"alg none", signTestJWT(t, "none", "", nil, validClaims()), JWTConfig{Keys: base.Keys, Algorithms: []string{"HS256", "none"}}, ErrInvalidToken},
+		{"disallowed alg", signTestJWT(t, "RS256", "", rsaKey, validClaims()), base, ErrInvalidToken},
+		{"key confusion", signTestJWT(t, "HS256", "", secret, validClaims()), JWTConfig{Keys: StaticJWTKey(&rsaKey.PublicKey), Algorithms: []string{"HS256"}}, ErrInvalidToken},
+		{"missing exp", signTestJWT(t, "HS256", "", secret, with(func(c map[string]any) { delete(c, "exp") })), base, ErrInvalidToken},
+		{"malformed", "not-a-jwt", base, ErrInvalidToken},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := VerifyJWT(context.Background(), c.token, c.cfg); !errors.Is(err, c.want) {
+				t.Errorf("Expected %v, got %v", c.want, err)
+			}
+		})
+	}
+
+	noExp := signTestJWT(t, "HS256", "", secret, with(func(c map[string]any) { delete(c, "exp") }))
+	if _, err := VerifyJWT(context.Background(), noExp, JWTConfig{Keys: base.Keys, Algorithms: base.Algorithms, AllowMissingExpiration: true}); err != nil {
+		t.Errorf("Expected AllowMissingExpiration to accept tokens without exp, got %v", err)
+	}
+}
+
+func TestVerifyJWT_ClockSkew(t *testing.T) {
+	secret := []byte("super-secret")
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-10 * time.Second).Unix()
+	token := signTestJWT(t, "HS256", "", secret, claims)
+
+	cfg := JWTConfig{Keys: StaticJWTKey(secret), Algorithms: []string{"HS256"}, ClockSkew: time.Minute}
+	if _, err := VerifyJWT(context.Background(), token, cfg); err != nil {
+		t.Errorf("Expected token within clock skew to be accepted, got %v", err)
+	}
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	secret := []byte("super-secret")
+	mw := Middlewares.JWT(StaticJWTKey(secret))
+
+	var principal *Principal
+	handler := mw(func(z *Z) { principal = z.Principal() })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestJWT(t, "HS256", "", secret, validClaims()))
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+
+	if rr.Code != http.StatusOK || principal == nil {
+		t.Fatalf("Expected authenticated request, got %d", rr.Code)
+	}
+	if principal.Subject != "user-1" || principal.Method != "jwt" {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+	if len(principal.Scopes) != 2 || principal.Scopes[1] != "orders:write" || len(principal.Roles) != 1 || principal.Roles[0] != "admin" {
+		t.Errorf("Unexpected scopes/roles %v %v", principal.Scopes, principal.Roles)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer garbage")
+	rr = httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") != `Bearer error="invalid_token"` {
+		t.Errorf("Expected invalid_token 401, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func testJWKS(t *testing.T) ([]byte, *rsa.PrivateKey, ed25519.PrivateKey) {
+	t.Helper()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	enc := base64.RawURLEncoding.EncodeToString
+	data, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": enc(rsaKey.N.Bytes()), "e": enc(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": enc(edPub)},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "", "e": ""},
+	}})
+	return data, rsaKey, edPriv
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	data, rsaKey, edPriv := testJWKS(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := LoadJWKSFile(path)
+	if err != nil {
+		t.Fatalf("LoadJWKSFile failed: %v", err)
+	}
+	cfg := JWTConfig{Keys: jwks, Algorithms: []string{"RS256", "EdDSA"}}
+
+	for _, token := range []string{
+		signTestJWT(t, "RS256", "rsa-1", rsaKey, validClaims()),
+		signTestJWT(t, "EdDSA", "ed-1", edPriv, validClaims()),
+	} {
+		if _, err := VerifyJWT(context.Background(), token, cfg); err != nil {
+			t.Errorf("VerifyJWT failed: %v", err)
+		}
+	}
+
+	if _, err := VerifyJWT(context.Background(), signTestJWT(t, "RS256", "unknown", rsaKey, validClaims()), cfg); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err := LoadJWKSFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestRemoteJWKS_Caching(t *testing.T) {
+	data, rsaKey, _ := testJWKS(t)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+	cfg := JWTConfig{Keys: jwks, Algorithms: []string{"RS256"}}
+	token := signTestJWT(t, "RS256", "rsa-1", rsaKey, validClaims())
+
+	for i := 0; i < 3; i++ {
+		if _, err := VerifyJWT(context.Background(), token, cfg); err != nil {
+			t.Fatalf("VerifyJWT failed: %v", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Expected JWKS to be fetched once, got %d", fetches)
+	}
+
+	VerifyJWT(context.Background(), signTestJWT(t, "RS256", "rotated", rsaKey, validClaims()), cfg)
+	if fetches != 1 {
+		t.Errorf("Unknown kids should not refetch within the minimum interval, got %d fetches", fetches)
+	}
+
+	jwks.minInterval = 0
+	VerifyJWT(context.Background(), signTestJWT(t, "RS256", "rotated", rsaKey, validClaims()), cfg)
+	if fetches != 2 {
+		t.Errorf("Unknown kids should trigger a refetch, got %d fetches", fetches)
+	}
+}
+
+func TestRemoteJWKS_FailureBackoff(t *testing.T) {
+	data, _, _ := testJWKS(t)
+	var failing atomic.Bool
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+	failing.Store(true)
+	for i := 0; i < 3; i++ {
+		if _, err := jwks.Key(context.Background(), "rsa-1", "RS256"); err == nil {
+			t.Fatal("Expected fetch error")
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("Expected failed fetches to back off, got %d fetches", got)
+	}
+
+	jwks.minInterval = 0
+	failing.Store(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := jwks.Key(ctx, "rsa-1", "RS256"); err != nil {
+		t.Fatalf("Expected fetch to ignore request cancellation, got %v", err)
+	}
+
+	jwks.minInterval = time.Minute
+	failing.Store(true)
+	jwks.fetchedAt = time.Now().Add(-2 * time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := jwks.Key(context.Background(), "unknown", "RS256"); !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("Expected cached keys to answer while the endpoint is down, got %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 3 {
+		t.Errorf("Expected one failed refresh per interval, got %d fetches", got)
+	}
+}
+
+func TestRemoteJWKS_RefreshDoesNotBlockCachedKeys(t *testing.T) {
+	data, _, _ := testJWKS(t)
+	var slow atomic.Bool
+	started, release := make(chan struct{}), make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if slow.Load() {
+			close(started)
+			<-release
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+	if _, err := jwks.Key(context.Background(), "rsa-1", "RS256"); err != nil {
+		t.Fatalf("Key failed: %v", err)
+	}
+
+	jwks.minInterval = 0
+	slow.Store(true)
+	go jwks.Key(context.Background(), "rotated", "RS256")
+	<-started
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(context.Background(), "rsa-1", "RS256")
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Expected cached key during refresh, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Cached key lookup blocked on the JWKS refresh")
+	}
+
+	if _, err := jwks.Key(context.Background(), "other", "RS256"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected unknown kid to use cached keys during refresh, got %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("Expected a single in-flight refresh, got %d fetches", got)
+	}
+}
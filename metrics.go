@@ -0,0 +1,266 @@
+package z
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	DefaultMetricsBuckets     = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	DefaultMetricsSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+type metricType string
+
+const (
+	counterMetric   metricType = "counter"
+	gaugeMetric     metricType = "gauge"
+	histogramMetric metricType = "histogram"
+)
+
+type MetricsRegistry struct {
+	mu       sync.RWMutex
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{families: map[string]*metricFamily{}}
+}
+
+func (reg *MetricsRegistry) family(name, help string, typ metricType, buckets []float64, labels ...string) *metricFamily {
+	reg.mu.RLock()
+	f, ok := reg.families[name]
+	reg.mu.RUnlock()
+	if ok {
+		return f
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if f, ok := reg.families[name]; ok {
+		return f
+	}
+	f = &metricFamily{name: name, help: help, typ: typ, labels: labels, buckets: buckets, series: map[string]*metricSeries{}}
+	reg.families[name] = f
+	return f
+}
+
+func (f *metricFamily) get(values []string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: slices.Clone(values)}
+		if f.typ == histogramMetric {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *metricFamily) add(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.get(values).value += v
+}
+
+func (f *metricFamily) observe(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.get(values)
+	for i, upper := range f.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (reg *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	reg.mu.RLock()
+	families := make([]*metricFamily, 0, len(reg.families))
+	for _, f := range reg.families {
+		families = append(families, f)
+	}
+	reg.mu.RUnlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (reg *MetricsRegistry) Handler() HandlerFunc {
+	return func(z *Z) {
+		z.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		z.rw.WriteHeader(http.StatusOK)
+		reg.WriteTo(z.rw)
+	}
+}
+
+func (f *metricFamily) write(b *strings.Builder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.typ)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.typ != histogramMetric {
+			fmt.Fprintf(b, "%s%s %s\n", f.name, formatLabels(f.labels, s.labelValues, ""), formatMetricValue(s.value))
+			continue
+		}
+		for i, upper := range f.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, formatMetricValue(upper)), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labelValues, ""), formatMetricValue(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labelValues, ""), s.count)
+	}
+}
+
+func formatLabels(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (app *App) MetricsHandler() HandlerFunc {
+	return app.metrics.Handler()
+}
+
+type MetricsConfig struct {
+	Registry    *MetricsRegistry
+	Namespace   string
+	Buckets     []float64
+	SizeBuckets []float64
+	Skip        func(z *Z) bool
+}
+
+func (middlewaresRegistry) Metrics() MiddlewareFunc {
+	return Middlewares.MetricsWithCfg(MetricsConfig{})
+}
+
+func (middlewaresRegistry) MetricsWithCfg(cfg MetricsConfig) MiddlewareFunc {
+	if cfg.Buckets == nil {
+		cfg.Buckets = DefaultMetricsBuckets
+	}
+	if cfg.SizeBuckets == nil {
+		cfg.SizeBuckets = DefaultMetricsSizeBuckets
+	}
+	prefix := "http_"
+	if cfg.Namespace != "" {
+		prefix = cfg.Namespace + "_http_"
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			registry := cfg.Registry
+			if registry == nil && z.app != nil {
+				registry = z.app.metrics
+			}
+			if registry == nil || (cfg.Skip != nil && cfg.Skip(z)) {
+				next(z)
+				return
+			}
+
+			requests := registry.family(prefix+"requests_total", "Total number of HTTP requests.", counterMetric, nil, "method", "route", "status")
+			duration := registry.family(prefix+"request_duration_seconds", "HTTP request latency in seconds.", histogramMetric, cfg.Buckets, "method", "route", "status")
+			requestSize := registry.family(prefix+"request_size_bytes", "HTTP request body size in bytes.", histogramMetric, cfg.SizeBuckets, "method", "route", "status")
+			responseSize := registry.family(prefix+"response_size_bytes", "HTTP response body size in bytes.", histogramMetric, cfg.SizeBuckets, "method", "route", "status")
+			inFlight := registry.family(prefix+"requests_in_flight", "Number of HTTP requests currently being served.", gaugeMetric, nil)
+
+			inFlight.add(1)
+			start := time.Now()
+			writer := &responseWriter{ResponseWriter: z.rw}
+			z.rw = writer
+			completed := false
+
+			defer func() {
+				inFlight.add(-1)
+
+				status := writer.status
+				switch {
+				case !completed && !writer.wroteHeader:
+					status = http.StatusInternalServerError
+				case status == 0:
+					status = http.StatusOK
+				}
+				route := z.RoutePattern()
+				if route == "" {
+					route = "unmatched"
+				}
+				labels := []string{z.r.Method, route, strconv.Itoa(status/100) + "xx"}
+
+				requests.add(1, labels...)
+				duration.observe(time.Since(start).Seconds(), labels...)
+				requestSize.observe(float64(max(z.r.ContentLength, 0)), labels...)
+				responseSize.observe(float64(writer.size), labels...)
+			}()
+
+			next(z)
+			completed = true
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package z
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.Metrics())
+	app.GET("/users/{id}", func(z *Z) { z.Ok("user") })
+	app.POST("/users", func(z *Z) { z.String(http.StatusBadRequest, "bad") })
+	app.GET("/metrics", app.MetricsHandler())
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/3"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users", strings.NewReader("12345")))
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body := rr.Body.String()
+
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Unexpected content type %q", rr.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		"# TYPE http_requests_total counter",
+		`http_requests_total{method="GET",route="/users/{id}",status="2xx"} 3`,
+		`http_requests_total{method="POST",route="/users",status="4xx"} 1`,
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_bucket{method="GET",route="/users/{id}",status="2xx",le="+Inf"} 3`,
+		`http_request_duration_seconds_count{method="GET",route="/users/{id}",status="2xx"} 3`,
+		`http_request_size_bytes_sum{method="POST",route="/users",status="4xx"} 5`,
+		`http_response_size_bytes_sum{method="GET",route="/users/{id}",status="2xx"} 12`,
+		`http_response_size_bytes_bucket{method="GET",route="/users/{id}",status="2xx",le="100"} 3`,
+		"# TYPE http_requests_in_flight gauge",
+		"http_requests_in_flight 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "/users/1") {
+		t.Fatal("Expected raw paths not to be used as labels")
+	}
+}
+
+func TestMetricsMiddleware_PanicCountsAsServerError(t *testing.T) {
+	registry := NewMetricsRegistry()
+	handler := Middlewares.MetricsWithCfg(MetricsConfig{Registry: registry, Namespace: "shop"})(func(z *Z) { panic("boom") })
+
+	func() {
+		defer func() { recover() }()
+		handler(&Z{rw: httptest.NewRecorder(), r: httptest.NewRequest("GET", "/", nil), route: &Route{Pattern: "/"}})
+	}()
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+	if !strings.Contains(b.String(), `shop_http_requests_total{method="GET",route="/",status="5xx"} 1`) {
+		t.Fatalf("Expected panic to be recorded as 5xx:\n%s", b.String())
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Fatalf("Unexpected escape: %q", got)
+	}
+}
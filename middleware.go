@@ -1,16 +1,25 @@
 package z
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"runtime/debug"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,26 +29,113 @@ type middlewaresRegistry struct{}
 
 type responseWriter struct {
 	http.ResponseWriter
-	body   *bytes.Buffer
-	status int
+	body          *bytes.Buffer
+	bodyLimit     int
+	truncated     bool
+	status        int
+	size          int
+	wroteHeader   bool
+	beforeHeaders func()
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
-	rw.body.Write(b)
-	return rw.ResponseWriter.Write(b)
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.body != nil {
+		rw.capture(b)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
 }
 
 func (rw *responseWriter) WriteHeader(statusCode int) {
-	rw.status = statusCode
+	if !rw.wroteHeader {
+		if hook := rw.beforeHeaders; hook != nil {
+			rw.beforeHeaders = nil
+			hook()
+		}
+		rw.status = statusCode
+		rw.wroteHeader = true
+	}
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil && !rw.wroteHeader {
+		rw.status = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return conn, buf, err
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseWriter) capture(b []byte) {
+	if rw.bodyLimit < 0 {
+		rw.body.Write(b)
+		return
+	}
+	remaining := rw.bodyLimit - rw.body.Len()
+	if len(b) > remaining {
+		b = b[:max(remaining, 0)]
+		rw.truncated = true
+	}
+	rw.body.Write(b)
+}
+
 var Middlewares = middlewaresRegistry{}
 
+const (
+	DefaultLogMaxBodyBytes = 4096
+	redactedValue          = "[REDACTED]"
+)
+
+var DefaultLogBodyContentTypes = []string{
+	"application/json",
+	"application/*+json",
+	"application/xml",
+	"application/x-www-form-urlencoded",
+	"text/*",
+}
+
+var DefaultRedactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+var DefaultRedactJSONPaths = []string{
+	"$..password",
+	"$..token",
+	"$..access_token",
+	"$..refresh_token",
+	"$..secret",
+	"$..client_secret",
+}
+
 type LoggingConfig struct {
-	LogRequestBody  bool
-	LogResponseBody bool
-	LogFilePath     string
+	LogRequestBody   bool
+	LogResponseBody  bool
+	LogHeaders       bool
+	LogFilePath      string
+	MaxBodyBytes     int
+	BodyContentTypes []string
+	RedactHeaders    []string
+	RedactJSONPaths  []string
 }
 
 func (mr middlewaresRegistry) Logging() MiddlewareFunc {
@@ -47,6 +143,28 @@ func (mr middlewaresRegistry) Logging() MiddlewareFunc {
 }
 
 func (mr middlewaresRegistry) LoggingWithCfg(cfg LoggingConfig) MiddlewareFunc {
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = DefaultLogMaxBodyBytes
+	}
+	if cfg.BodyContentTypes == nil {
+		cfg.BodyContentTypes = DefaultLogBodyContentTypes
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = DefaultRedactHeaders
+	}
+	if cfg.RedactJSONPaths == nil {
+		cfg.RedactJSONPaths = DefaultRedactJSONPaths
+	}
+
+	redactHeaders := make(map[string]bool, len(cfg.RedactHeaders))
+	for _, name := range cfg.RedactHeaders {
+		redactHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+	redactPaths := make([][]string, 0, len(cfg.RedactJSONPaths))
+	for _, p := range cfg.RedactJSONPaths {
+		redactPaths = append(redactPaths, parseJSONPath(p))
+	}
+
 	return func(next HandlerFunc) HandlerFunc {
 		return func(z *Z) {
 			if cfg.LogFilePath != "" {
@@ -62,28 +180,32 @@ func (mr middlewaresRegistry) LoggingWithCfg(cfg LoggingConfig) MiddlewareFunc {
 			start := time.Now()
 
 			var requestBody []byte
+			var requestTruncated bool
 			if cfg.LogRequestBody && z.r.Body != nil {
 				var err error
-				requestBody, err = io.ReadAll(z.r.Body)
+				requestBody, requestTruncated, err = captureRequestBody(z.r, cfg.MaxBodyBytes)
 				if err != nil {
 					slog.Error("Error reading request body", "err", err)
 				}
-				z.r.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 			}
 
-			writer := &responseWriter{
-				ResponseWriter: z.rw, body: &bytes.Buffer{},
+			writer := &responseWriter{ResponseWriter: z.rw}
+			if cfg.LogResponseBody {
+				writer.body = &bytes.Buffer{}
+				writer.bodyLimit = cfg.MaxBodyBytes
 			}
 			z.rw = writer
 
 			next(z)
 
 			latency := time.Since(start)
-			reqID := z.r.Header.Get("X-Request-ID")
+			reqID := requestIDOf(z)
 
 			logAttrs := []slog.Attr{
 				slog.String("method", z.r.Method),
 				slog.String("path", z.r.URL.Path),
+				slog.String("route", z.RoutePattern()),
+				slog.String("client_ip", z.ClientIP()),
 				slog.Int("status", writer.status),
 				slog.Duration("latency", latency),
 			}
@@ -92,11 +214,17 @@ func (mr middlewaresRegistry) LoggingWithCfg(cfg LoggingConfig) MiddlewareFunc {
 				logAttrs = append(logAttrs, slog.String("request_id", reqID))
 			}
 
+			if cfg.LogHeaders {
+				logAttrs = append(logAttrs,
+					slog.Any("request_headers", redactHeaderValues(z.r.Header, redactHeaders)),
+					slog.Any("response_headers", redactHeaderValues(writer.Header(), redactHeaders)),
+				)
+			}
 			if cfg.LogRequestBody && len(requestBody) > 0 {
-				logAttrs = append(logAttrs, slog.String("request_body", string(requestBody)))
+				logAttrs = append(logAttrs, bodyLogAttrs("request_body", z.r.Header.Get("Content-Type"), requestBody, requestTruncated, cfg.BodyContentTypes, redactPaths)...)
 			}
 			if cfg.LogResponseBody && writer.body.Len() > 0 {
-				logAttrs = append(logAttrs, slog.String("response_body", writer.body.String()))
+				logAttrs = append(logAttrs, bodyLogAttrs("response_body", writer.Header().Get("Content-Type"), writer.body.Bytes(), writer.truncated, cfg.BodyContentTypes, redactPaths)...)
 			}
 			args := make([]any, len(logAttrs))
 			for i, attr := range logAttrs {
@@ -107,12 +235,207 @@ func (mr middlewaresRegistry) LoggingWithCfg(cfg LoggingConfig) MiddlewareFunc {
 	}
 }
 
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func captureRequestBody(r *http.Request, limit int) ([]byte, bool, error) {
+	if limit < 0 {
+		body, err := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return body, false, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if len(body) > limit {
+		return body[:limit], true, err
+	}
+	return body, false, err
+}
+
+func bodyLogAttrs(key, contentType string, body []byte, truncated bool, allowed []string, redactPaths [][]string) []slog.Attr {
+	if contentType == "" && len(body) > 0 {
+		contentType = sniffContentType(body)
+	}
+	if contentType != "" && !matchContentType(contentType, allowed) {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		return []slog.Attr{slog.String(key, "[omitted "+mediaType+"]")}
+	}
+
+	attrs := []slog.Attr{slog.String(key, redactBody(contentType, body, truncated, redactPaths))}
+	if truncated {
+		attrs = append(attrs, slog.Bool(key+"_truncated", true))
+	}
+	return attrs
+}
+
+func sniffContentType(body []byte) string {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "application/json"
+	}
+	return http.DetectContentType(body)
+}
+
+func matchContentType(contentType string, patterns []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range patterns {
+		switch {
+		case pattern == "*/*" || pattern == mediaType:
+			return true
+		case strings.HasSuffix(pattern, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case strings.Contains(pattern, "/*+"):
+			prefix, suffix, _ := strings.Cut(pattern, "*")
+			if strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isJSONContentType(contentType string) bool {
+	return matchContentType(contentType, []string{"application/json", "application/*+json"})
+}
+
+func redactBody(contentType string, body []byte, truncated bool, redactPaths [][]string) string {
+	if len(redactPaths) == 0 {
+		return string(body)
+	}
+
+	switch {
+	case isJSONContentType(contentType):
+		if truncated {
+			return "[omitted truncated JSON]"
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return "[omitted invalid JSON]"
+		}
+		out, err := json.Marshal(redactJSONValue(v, nil, redactPaths))
+		if err != nil {
+			return "[omitted invalid JSON]"
+		}
+		return string(out)
+	case matchContentType(contentType, []string{"application/x-www-form-urlencoded"}):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "[omitted invalid form]"
+		}
+		for key := range values {
+			if matchAnyJSONPath(redactPaths, []string{key}) {
+				values[key] = []string{redactedValue}
+			}
+		}
+		return values.Encode()
+	}
+	return string(body)
+}
+
+func redactJSONValue(v any, path []string, redactPaths [][]string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, child := range val {
+			childPath := append(path[:len(path):len(path)], key)
+			if matchAnyJSONPath(redactPaths, childPath) {
+				val[key] = redactedValue
+				continue
+			}
+			val[key] = redactJSONValue(child, childPath, redactPaths)
+		}
+	case []any:
+		for i, child := range val {
+			val[i] = redactJSONValue(child, path, redactPaths)
+		}
+	}
+	return v
+}
+
+func parseJSONPath(p string) []string {
+	p = strings.TrimPrefix(p, "$")
+	var segments []string
+	for p != "" {
+		if strings.HasPrefix(p, "..") {
+			segments = append(segments, "**")
+			p = p[2:]
+		} else {
+			p = strings.TrimPrefix(p, ".")
+		}
+		name := p
+		if i := strings.IndexByte(p, '.'); i >= 0 {
+			name, p = p[:i], p[i:]
+		} else {
+			p = ""
+		}
+		if i := strings.IndexByte(name, '['); i >= 0 {
+			name = name[:i]
+		}
+		if name != "" {
+			segments = append(segments, name)
+		}
+	}
+	return segments
+}
+
+func matchAnyJSONPath(patterns [][]string, path []string) bool {
+	for _, pattern := range patterns {
+		if matchJSONPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchJSONPath(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchJSONPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if pattern[0] != "*" && !strings.EqualFold(pattern[0], path[0]) {
+		return false
+	}
+	return matchJSONPath(pattern[1:], path[1:])
+}
+
+func redactHeaderValues(h http.Header, redact map[string]bool) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if redact[http.CanonicalHeaderKey(name)] {
+			out[name] = redactedValue
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
 func openLogFile(path string) (*os.File, error) {
 	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 }
 
 type RecoveryConfig struct {
-	LogPanic bool
+	LogPanic     bool
+	JSON         bool
+	PanicHandler func(z *Z, recovered any, stack []byte)
 }
 
 func (middlewaresRegistry) Recovery() MiddlewareFunc {
@@ -122,21 +445,66 @@ func (middlewaresRegistry) Recovery() MiddlewareFunc {
 func (middlewaresRegistry) RecoveryWithCfg(cfg RecoveryConfig) MiddlewareFunc {
 	return func(next HandlerFunc) HandlerFunc {
 		return func(z *Z) {
+			writer := &responseWriter{ResponseWriter: z.rw}
+			z.rw = writer
+
 			defer func() {
-				if err := recover(); err != nil {
-					if cfg.LogPanic {
-						log.Printf("Recovered from panic: %v", err)
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				stack := debug.Stack()
+				reqID := requestIDOf(z)
+
+				if cfg.LogPanic {
+					attrs := []any{
+						slog.Any("panic", recovered),
+						slog.String("method", z.r.Method),
+						slog.String("path", z.r.URL.Path),
 					}
-					z.String(http.StatusInternalServerError, "Internal Server Error")
+					if reqID != "" {
+						attrs = append(attrs, slog.String("request_id", reqID))
+					}
+					attrs = append(attrs, slog.String("stack", string(stack)))
+					slog.Error("Recovered from panic", attrs...)
+				}
+
+				if cfg.PanicHandler != nil {
+					cfg.PanicHandler(z, recovered, stack)
+				}
+
+				if writer.wroteHeader {
+					return
+				}
+
+				z.rw = writer
+				if cfg.JSON {
+					body := map[string]string{"error": http.StatusText(http.StatusInternalServerError)}
+					if reqID != "" {
+						body["request_id"] = reqID
+					}
+					z.JSON(http.StatusInternalServerError, body)
+					return
 				}
+				z.String(http.StatusInternalServerError, "Internal Server Error")
 			}()
 			next(z)
 		}
 	}
 }
 
+const DefaultRequestIDMaxLength = 128
+
 type RequestIDConfig struct {
-	HeaderName string
+	HeaderName    string
+	Generator     func() string
+	MaxLength     int
+	Validator     func(id string) bool
+	RejectInvalid bool
 }
 
 func (middlewaresRegistry) RequestID() MiddlewareFunc {
@@ -144,14 +512,36 @@ func (middlewaresRegistry) RequestID() MiddlewareFunc {
 }
 
 func (middlewaresRegistry) RequestIDWithCfg(cfg RequestIDConfig) MiddlewareFunc {
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-Request-ID"
+	}
+	if cfg.Generator == nil {
+		cfg.Generator = generateRequestID
+	}
+	if cfg.MaxLength == 0 {
+		cfg.MaxLength = DefaultRequestIDMaxLength
+	}
+	if cfg.Validator == nil {
+		cfg.Validator = isValidRequestID
+	}
+
 	return func(next HandlerFunc) HandlerFunc {
 		return func(z *Z) {
 			reqID := z.r.Header.Get(cfg.HeaderName)
+			if reqID != "" && (len(reqID) > cfg.MaxLength || !cfg.Validator(reqID)) {
+				if cfg.RejectInvalid {
+					z.Error(fmt.Errorf("invalid %s header", cfg.HeaderName), http.StatusBadRequest)
+					return
+				}
+				reqID = ""
+			}
 			if reqID == "" {
-				reqID = generateRequestID()
+				reqID = cfg.Generator()
 			}
 			z.r.Header.Set(cfg.HeaderName, reqID)
 			z.rw.Header().Set(cfg.HeaderName, reqID)
+			z.setContextValue(requestIDContextKey, reqID)
+			z.setContextValue(loggerContextKey, z.Logger().With(slog.String("request_id", reqID)))
 			next(z)
 		}
 	}
@@ -163,88 +553,370 @@ func generateRequestID() string {
 	return "req-" + hex.EncodeToString(b)
 }
 
+func isValidRequestID(id string) bool {
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '=', c == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func requestIDOf(z *Z) string {
+	if id := z.RequestID(); id != "" {
+		return id
+	}
+	return z.r.Header.Get("X-Request-ID")
+}
+
 type CORSConfig struct {
-	AllowOrigin      string
-	AllowMethods     string
-	AllowHeaders     string
-	AllowCredentials bool
-	MaxAge           int
+	AllowOrigin         string
+	AllowOrigins        []string
+	AllowOriginFunc     func(origin string) bool
+	AllowMethods        string
+	AllowHeaders        string
+	ExposeHeaders       string
+	AllowCredentials    bool
+	AllowPrivateNetwork bool
+	MaxAge              int
 }
 
 func (middlewaresRegistry) CORS() MiddlewareFunc {
 	return Middlewares.CORSWithCfg(CORSConfig{
-		AllowOrigin:      "*",
-		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-		AllowHeaders:     "Content-Type,Authorization",
-		AllowCredentials: true,
-		MaxAge:           3600,
+		AllowOrigins: []string{"*"},
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders: "Content-Type,Authorization",
+		MaxAge:       3600,
 	})
 }
 
 func (middlewaresRegistry) CORSWithCfg(cfg CORSConfig) MiddlewareFunc {
+	origins := slices.Clone(cfg.AllowOrigins)
+	if cfg.AllowOrigin != "" {
+		origins = append(origins, cfg.AllowOrigin)
+	}
+	wildcard := slices.Contains(origins, "*")
+
+	allowed := func(origin string) bool {
+		for _, o := range origins {
+			if matchOrigin(o, origin) {
+				return true
+			}
+		}
+		return cfg.AllowOriginFunc != nil && cfg.AllowOriginFunc(origin)
+	}
+
 	return func(next HandlerFunc) HandlerFunc {
 		return func(z *Z) {
-			z.rw.Header().Set("Access-Control-Allow-Origin", cfg.AllowOrigin)
-			z.rw.Header().Set("Access-Control-Allow-Methods", cfg.AllowMethods)
-			z.rw.Header().Set("Access-Control-Allow-Headers", cfg.AllowHeaders)
-			if cfg.AllowCredentials {
-				z.rw.Header().Set("Access-Control-Allow-Credentials", "true")
+			header := z.rw.Header()
+			origin := z.r.Header.Get("Origin")
+			preflight := z.r.Method == http.MethodOptions && origin != "" && z.r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !wildcard {
+				header.Add("Vary", "Origin")
 			}
-			if cfg.MaxAge > 0 {
-				z.rw.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			if preflight {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+				if cfg.AllowPrivateNetwork {
+					header.Add("Vary", "Access-Control-Request-Private-Network")
+				}
 			}
-			if z.r.Method == "OPTIONS" {
-				z.rw.WriteHeader(http.StatusNoContent)
+
+			switch {
+			case wildcard:
+				header.Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && allowed(origin):
+				header.Set("Access-Control-Allow-Origin", origin)
+				if cfg.AllowCredentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+			default:
+				if preflight {
+					z.rw.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next(z)
 				return
 			}
-			next(z)
+
+			if !preflight {
+				if cfg.ExposeHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", cfg.ExposeHeaders)
+				}
+				next(z)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Methods", cfg.AllowMethods)
+			if cfg.AllowHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", cfg.AllowHeaders)
+			} else if requested := z.r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			if cfg.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			if cfg.AllowPrivateNetwork && z.r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+				header.Set("Access-Control-Allow-Private-Network", "true")
+			}
+			z.rw.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func matchOrigin(pattern, origin string) bool {
+	if strings.EqualFold(pattern, origin) {
+		return true
+	}
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok || len(origin) <= len(prefix)+len(suffix) {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	if !strings.HasPrefix(origin, strings.ToLower(prefix)) || !strings.HasSuffix(origin, strings.ToLower(suffix)) {
+		return false
+	}
+	for _, c := range origin[len(prefix) : len(origin)-len(suffix)] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
 		}
 	}
+	return true
+}
+
+const CSPNonceSource = "'nonce-{nonce}'"
+
+var securityHeaderNames = []string{
+	"X-Content-Type-Options",
+	"X-Frame-Options",
+	"X-XSS-Protection",
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"Content-Security-Policy-Report-Only",
+	"Reporting-Endpoints",
+	"Referrer-Policy",
+	"Permissions-Policy",
+	"Cross-Origin-Opener-Policy",
+	"Cross-Origin-Embedder-Policy",
+	"Cross-Origin-Resource-Policy",
 }
 
 type SecurityHeadersConfig struct {
-	ContentTypeOptions    string
-	FrameOptions          string
-	XSSProtection         string
-	StrictTransportPolicy string
-	ContentSecurityPolicy string
-	ReferrerPolicy        string
+	ContentTypeOptions        string
+	FrameOptions              string
+	XSSProtection             string
+	StrictTransportPolicy     string
+	ContentSecurityPolicy     string
+	CSPReportOnly             bool
+	CSPReportURI              string
+	ReferrerPolicy            string
+	PermissionsPolicy         string
+	CrossOriginOpenerPolicy   string
+	CrossOriginEmbedderPolicy string
+	CrossOriginResourcePolicy string
+}
+
+func DefaultSecurityHeadersConfig() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		ContentTypeOptions:        "nosniff",
+		FrameOptions:              "DENY",
+		StrictTransportPolicy:     "max-age=31536000; includeSubDomains",
+		ContentSecurityPolicy:     "default-src 'self'",
+		ReferrerPolicy:            "no-referrer",
+		PermissionsPolicy:         "camera=(), microphone=(), geolocation=()",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+	}
 }
 
 func (middlewaresRegistry) SecurityHeaders() MiddlewareFunc {
-	return Middlewares.SecurityHeadersWithCfg(SecurityHeadersConfig{
-		ContentTypeOptions:    "nosniff",
-		FrameOptions:          "DENY",
-		XSSProtection:         "1; mode=block",
-		StrictTransportPolicy: "max-age=31536000; includeSubDomains",
-		ContentSecurityPolicy: "default-src 'self'",
-		ReferrerPolicy:        "no-referrer",
-	})
+	return Middlewares.SecurityHeadersWithCfg(DefaultSecurityHeadersConfig())
+}
+
+func (middlewaresRegistry) DisableSecurityHeaders() MiddlewareFunc {
+	return Middlewares.SecurityHeadersWithCfg(SecurityHeadersConfig{})
 }
 
 func (middlewaresRegistry) SecurityHeadersWithCfg(cfg SecurityHeadersConfig) MiddlewareFunc {
+	csp := cfg.ContentSecurityPolicy
+	if csp != "" && cfg.CSPReportURI != "" {
+		csp += "; report-uri " + cfg.CSPReportURI + "; report-to csp-endpoint"
+	}
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	usesNonce := strings.Contains(csp, "{nonce}")
+
 	return func(next HandlerFunc) HandlerFunc {
 		return func(z *Z) {
-			if cfg.ContentTypeOptions != "" {
-				z.rw.Header().Set("X-Content-Type-Options", cfg.ContentTypeOptions)
+			header := z.rw.Header()
+			for _, name := range securityHeaderNames {
+				header.Del(name)
 			}
-			if cfg.FrameOptions != "" {
-				z.rw.Header().Set("X-Frame-Options", cfg.FrameOptions)
+
+			set := func(name, value string) {
+				if value != "" {
+					header.Set(name, value)
+				}
 			}
-			if cfg.XSSProtection != "" {
-				z.rw.Header().Set("X-XSS-Protection", cfg.XSSProtection)
+			set("X-Content-Type-Options", cfg.ContentTypeOptions)
+			set("X-Frame-Options", cfg.FrameOptions)
+			set("X-XSS-Protection", cfg.XSSProtection)
+			if z.Scheme() == "https" {
+				set("Strict-Transport-Security", cfg.StrictTransportPolicy)
 			}
-			if cfg.StrictTransportPolicy != "" {
-				z.rw.Header().Set("Strict-Transport-Security", cfg.StrictTransportPolicy)
+			set("Referrer-Policy", cfg.ReferrerPolicy)
+			set("Permissions-Policy", cfg.PermissionsPolicy)
+			set("Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy)
+			set("Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy)
+			set("Cross-Origin-Resource-Policy", cfg.CrossOriginResourcePolicy)
+
+			if csp != "" {
+				policy := csp
+				if usesNonce {
+					nonce := z.CSPNonce()
+					if nonce == "" {
+						nonce = generateCSPNonce()
+						z.setContextValue(cspNonceContextKey, nonce)
+					}
+					policy = strings.ReplaceAll(policy, "{nonce}", nonce)
+				}
+				header.Set(cspHeader, policy)
+				if cfg.CSPReportURI != "" {
+					header.Set("Reporting-Endpoints", fmt.Sprintf("csp-endpoint=%q", cfg.CSPReportURI))
+				}
 			}
-			if cfg.ContentSecurityPolicy != "" {
-				z.rw.Header().Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			next(z)
+		}
+	}
+}
+
+func generateCSPNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func (z *Z) CSPNonce() string {
+	nonce, _ := z.r.Context().Value(cspNonceContextKey).(string)
+	return nonce
+}
+
+type CSPBuilder struct {
+	directives []string
+	sources    map[string][]string
+}
+
+func NewCSP() *CSPBuilder {
+	return &CSPBuilder{sources: map[string][]string{}}
+}
+
+func (b *CSPBuilder) Add(directive string, sources ...string) *CSPBuilder {
+	if _, ok := b.sources[directive]; !ok {
+		b.directives = append(b.directives, directive)
+	}
+	b.sources[directive] = append(b.sources[directive], sources...)
+	return b
+}
+
+func (b *CSPBuilder) String() string {
+	parts := make([]string, 0, len(b.directives))
+	for _, directive := range b.directives {
+		parts = append(parts, strings.TrimSpace(directive+" "+strings.Join(b.sources[directive], " ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+type CSPReport struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	OriginalPolicy     string `json:"original-policy"`
+	Disposition        string `json:"disposition"`
+	StatusCode         int    `json:"status-code"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	ColumnNumber       int    `json:"column-number"`
+	Sample             string `json:"script-sample"`
+}
+
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		Referrer           string `json:"referrer"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		OriginalPolicy     string `json:"originalPolicy"`
+		Disposition        string `json:"disposition"`
+		StatusCode         int    `json:"statusCode"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		ColumnNumber       int    `json:"columnNumber"`
+		Sample             string `json:"sample"`
+	} `json:"body"`
+}
+
+const maxCSPReportBytes = 64 << 10
+
+func CSPReportHandler(handle func(z *Z, report CSPReport)) HandlerFunc {
+	return func(z *Z) {
+		body, err := io.ReadAll(io.LimitReader(z.r.Body, maxCSPReportBytes))
+		if err != nil {
+			z.Error(fmt.Errorf("failed to read report"), http.StatusBadRequest)
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(z.r.Header.Get("Content-Type"))
+		var reports []CSPReport
+		if mediaType == "application/reports+json" {
+			var batch []reportingAPIReport
+			if err := json.Unmarshal(body, &batch); err != nil {
+				z.Error(fmt.Errorf("invalid report"), http.StatusBadRequest)
+				return
 			}
-			if cfg.ReferrerPolicy != "" {
-				z.rw.Header().Set("Referrer-Policy", cfg.ReferrerPolicy)
+			for _, r := range batch {
+				if r.Type != "csp-violation" {
+					continue
+				}
+				reports = append(reports, CSPReport{
+					DocumentURI:        r.Body.DocumentURL,
+					Referrer:           r.Body.Referrer,
+					BlockedURI:         r.Body.BlockedURL,
+					ViolatedDirective:  r.Body.EffectiveDirective,
+					EffectiveDirective: r.Body.EffectiveDirective,
+					OriginalPolicy:     r.Body.OriginalPolicy,
+					Disposition:        r.Body.Disposition,
+					StatusCode:         r.Body.StatusCode,
+					SourceFile:         r.Body.SourceFile,
+					LineNumber:         r.Body.LineNumber,
+					ColumnNumber:       r.Body.ColumnNumber,
+					Sample:             r.Body.Sample,
+				})
 			}
-			next(z)
+		} else {
+			var legacy struct {
+				Report CSPReport `json:"csp-report"`
+			}
+			if err := json.Unmarshal(body, &legacy); err != nil {
+				z.Error(fmt.Errorf("invalid report"), http.StatusBadRequest)
+				return
+			}
+			reports = append(reports, legacy.Report)
+		}
+
+		for _, report := range reports {
+			handle(z, report)
 		}
+		z.rw.WriteHeader(http.StatusNoContent)
 	}
 }
 
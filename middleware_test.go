@@ -273,6 +273,140 @@ func TestRecoveryMiddleware(t *testing.T) {
 	}
 }
 
+func TestRecoveryMiddleware_LogsStackAndRequestID(t *testing.T) {
+	var logOutput bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logOutput, nil)))
+
+	req := httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.Recovery()(func(z *Z) { panic("kaboom") })(z)
+
+	logStr := logOutput.String()
+	for _, want := range []string{`"msg":"Recovered from panic"`, `"panic":"kaboom"`, `"request_id":"req-42"`, `"stack":"goroutine`} {
+		if !strings.Contains(logStr, want) {
+			t.Errorf("Log output should contain %s, got: %s", want, logStr)
+		}
+	}
+}
+
+func TestRecoveryMiddleware_PanicHandlerAndJSON(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "req-7")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	var gotRecovered any
+	var gotStack []byte
+	mw := Middlewares.RecoveryWithCfg(RecoveryConfig{
+		JSON: true,
+		PanicHandler: func(z *Z, recovered any, stack []byte) {
+			gotRecovered = recovered
+			gotStack = stack
+		},
+	})
+	mw(func(z *Z) { panic("reported") })(z)
+
+	if gotRecovered != "reported" {
+		t.Errorf("PanicHandler got %v, want %q", gotRecovered, "reported")
+	}
+	if len(gotStack) == 0 {
+		t.Error("PanicHandler should receive a stack trace")
+	}
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", ct)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, `"error":"Internal Server Error"`) || !strings.Contains(body, `"request_id":"req-7"`) {
+		t.Errorf("Unexpected JSON body: %s", body)
+	}
+}
+
+func TestRecoveryMiddleware_PanicHandlerCanWriteResponse(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	mw := Middlewares.RecoveryWithCfg(RecoveryConfig{
+		PanicHandler: func(z *Z, recovered any, stack []byte) {
+			z.String(http.StatusServiceUnavailable, "custom")
+		},
+	})
+	mw(func(z *Z) { panic("x") })(z)
+
+	if rr.Code != http.StatusServiceUnavailable || rr.Body.String() != "custom" {
+		t.Errorf("Expected custom response, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRecoveryMiddleware_HeadersAlreadyWritten(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.Recovery()(func(z *Z) {
+		z.String(http.StatusOK, "partial")
+		panic("late panic")
+	})(z)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected committed status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Body.String() != "partial" {
+		t.Errorf("Expected no error body after committed response, got %q", rr.Body.String())
+	}
+}
+
+func TestRecoveryMiddleware_RepanicsErrAbortHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler to propagate, got %v", rec)
+		}
+	}()
+	Middlewares.Recovery()(func(z *Z) { panic(http.ErrAbortHandler) })(z)
+}
+
+func TestRecoveryMiddleware_Hijack(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.Recovery())
+	app.GET("/ws", func(z *Z) {
+		hijacker, ok := z.ResponseWriter().(http.Hijacker)
+		if !ok {
+			z.String(http.StatusInternalServerError, "not a hijacker")
+			return
+		}
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			z.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		buf.Flush()
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ws")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected hijacked connection to answer 101, got %d", resp.StatusCode)
+	}
+}
+
 func TestRequestIDMiddleware(t *testing.T) {
 	req := httptest.NewRequest("GET", "/", nil)
 	rr := httptest.NewRecorder()
@@ -299,8 +433,98 @@ func TestRequestIDMiddleware(t *testing.T) {
 	}
 }
 
+func TestRequestIDMiddleware_CustomGeneratorAndContext(t *testing.T) {
+	var logOutput bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logOutput, nil)))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	var fromAccessor, fromContext string
+	handler := func(z *Z) {
+		fromAccessor = z.RequestID()
+		fromContext = RequestIDFromContext(z.Context())
+		z.Logger().Info("inside handler")
+	}
+
+	Middlewares.RequestIDWithCfg(RequestIDConfig{Generator: UUIDv4})(handler)(z)
+
+	if len(fromAccessor) != 36 {
+		t.Errorf("Expected a UUID request ID, got %q", fromAccessor)
+	}
+	if fromContext != fromAccessor {
+		t.Errorf("Context request ID %q should match accessor %q", fromContext, fromAccessor)
+	}
+	if rr.Header().Get("X-Request-ID") != fromAccessor {
+		t.Errorf("Response header should carry the request ID")
+	}
+	if !strings.Contains(logOutput.String(), `"request_id":"`+fromAccessor+`"`) {
+		t.Errorf("Request-scoped logger should include request_id, got: %s", logOutput.String())
+	}
+}
+
+func TestRequestIDMiddleware_KeepsValidInboundID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "upstream-123")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	var got string
+	Middlewares.RequestID()(func(z *Z) { got = z.RequestID() })(z)
+
+	if got != "upstream-123" {
+		t.Errorf("Expected inbound request ID to be kept, got %q", got)
+	}
+}
+
+func TestRequestIDMiddleware_RegeneratesInvalidID(t *testing.T) {
+	cases := map[string]string{
+		"bad characters": "abc\n{\"evil\":true}",
+		"too long":       strings.Repeat("a", DefaultRequestIDMaxLength+1),
+	}
+	for name, inbound := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Request-ID", inbound)
+			rr := httptest.NewRecorder()
+			z := &Z{rw: rr, r: req}
+
+			var got string
+			Middlewares.RequestID()(func(z *Z) { got = z.RequestID() })(z)
+
+			if got == inbound || !strings.HasPrefix(got, "req-") {
+				t.Errorf("Expected a regenerated request ID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware_RejectsInvalidID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Correlation-ID", "not valid!")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	called := false
+	mw := Middlewares.RequestIDWithCfg(RequestIDConfig{
+		HeaderName:    "X-Correlation-ID",
+		RejectInvalid: true,
+	})
+	mw(func(z *Z) { called = true })(z)
+
+	if called {
+		t.Error("Handler should not run for a rejected request ID")
+	}
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
 func TestCORSMiddleware(t *testing.T) {
 	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
 	rr := httptest.NewRecorder()
 	z := &Z{rw: rr, r: req}
 
@@ -342,6 +566,177 @@ func TestCORSMiddleware_NonOptions_CallsNext(t *testing.T) {
 	}
 }
 
+func TestCORSMiddleware_DefaultDoesNotAllowCredentials(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.CORS()(func(z *Z) {})(z)
+
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("Wildcard origin must not be combined with credentials")
+	}
+}
+
+func TestCORSMiddleware_WildcardIgnoresCredentials(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.CORSWithCfg(CORSConfig{AllowOrigin: "*", AllowCredentials: true})(func(z *Z) {})(z)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Wildcard origin must not be reflected, got %q", got)
+	}
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("Wildcard origin must not be combined with credentials")
+	}
+}
+
+func TestCORSMiddleware_NonPreflightOptionsCallsNext(t *testing.T) {
+	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	called := false
+	Middlewares.CORS()(func(z *Z) { called = true })(z)
+
+	if !called {
+		t.Error("OPTIONS without Access-Control-Request-Method is not a preflight and should reach the handler")
+	}
+}
+
+func TestCORSMiddleware_OriginAllowlist(t *testing.T) {
+	mw := Middlewares.CORSWithCfg(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "https://partner.test" },
+		AllowMethods:     "GET,POST",
+		ExposeHeaders:    "X-Total-Count",
+		AllowCredentials: true,
+	})
+
+	cases := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://tenant.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://partner.test", true},
+		{"https://example.org", false},
+		{"https://evil.com/.example.org", false},
+		{"http://app.example.com", false},
+		{"https://evil.com", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Origin", c.origin)
+		rr := httptest.NewRecorder()
+		z := &Z{rw: rr, r: req}
+
+		called := false
+		mw(func(z *Z) { called = true })(z)
+
+		if !called {
+			t.Errorf("%s: actual requests should always reach the handler", c.origin)
+		}
+		got := rr.Header().Get("Access-Control-Allow-Origin")
+		if c.allowed && got != c.origin {
+			t.Errorf("%s: expected origin to be reflected, got %q", c.origin, got)
+		}
+		if !c.allowed && got != "" {
+			t.Errorf("%s: expected no Access-Control-Allow-Origin, got %q", c.origin, got)
+		}
+		if c.allowed && rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s: expected credentials to be allowed", c.origin)
+		}
+		if c.allowed && rr.Header().Get("Access-Control-Expose-Headers") != "X-Total-Count" {
+			t.Errorf("%s: expected Access-Control-Expose-Headers", c.origin)
+		}
+		if rr.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: expected Vary: Origin, got %q", c.origin, rr.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestCORSMiddleware_PreflightPrivateNetwork(t *testing.T) {
+	mw := Middlewares.CORSWithCfg(CORSConfig{
+		AllowOrigins:        []string{"https://app.example.com"},
+		AllowMethods:        "GET,PUT",
+		AllowPrivateNetwork: true,
+		MaxAge:              600,
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "x-custom")
+	req.Header.Set("Access-Control-Request-Private-Network", "true")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	called := false
+	mw(func(z *Z) { called = true })(z)
+
+	if called {
+		t.Error("Preflight should be answered by the middleware")
+	}
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":          "https://app.example.com",
+		"Access-Control-Allow-Methods":         "GET,PUT",
+		"Access-Control-Allow-Headers":         "x-custom",
+		"Access-Control-Max-Age":               "600",
+		"Access-Control-Allow-Private-Network": "true",
+	}
+	for k, v := range want {
+		if got := rr.Header().Get(k); got != v {
+			t.Errorf("Expected %s %q, got %q", k, v, got)
+		}
+	}
+}
+
+func TestCORSMiddleware_PreflightDisallowedOrigin(t *testing.T) {
+	mw := Middlewares.CORSWithCfg(CORSConfig{AllowOrigins: []string{"https://app.example.com"}})
+
+	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	mw(func(z *Z) { t.Error("handler should not be called for a preflight") })(z)
+
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" || rr.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("Disallowed origin should not receive CORS headers: %v", rr.Header())
+	}
+}
+
+func TestCORSMiddleware_AutoOptions(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.CORSWithCfg(CORSConfig{AllowOrigins: []string{"https://app.example.com"}, AllowMethods: "GET"}))
+	app.AutoOptions()
+	app.GET("/items/{id}", func(z *Z) { z.Ok("item") })
+
+	req := httptest.NewRequest("OPTIONS", "/items/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected preflight status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected preflight to be answered by the CORS middleware")
+	}
+}
+
 func TestSecurityHeadersMiddleware(t *testing.T) {
 	req := httptest.NewRequest("GET", "/", nil)
 	rr := httptest.NewRecorder()
@@ -358,6 +753,156 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 	}
 }
 
+func TestSecurityHeadersMiddleware_Defaults(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.SecurityHeaders()(func(z *Z) {})(z)
+
+	if rr.Header().Get("X-XSS-Protection") != "" {
+		t.Error("Deprecated X-XSS-Protection should not be sent by default")
+	}
+	if rr.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS should not be sent over plain HTTP")
+	}
+	for _, name := range []string{"Permissions-Policy", "Cross-Origin-Opener-Policy", "Cross-Origin-Resource-Policy", "Content-Security-Policy"} {
+		if rr.Header().Get(name) == "" {
+			t.Errorf("Expected %s to be set", name)
+		}
+	}
+}
+
+func TestSecurityHeadersMiddleware_HSTSOverTLS(t *testing.T) {
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.SecurityHeaders()(func(z *Z) {})(z)
+
+	if rr.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("Expected HSTS over TLS")
+	}
+}
+
+func TestSecurityHeadersMiddleware_CSPNonce(t *testing.T) {
+	cfg := DefaultSecurityHeadersConfig()
+	cfg.ContentSecurityPolicy = NewCSP().
+		Add("default-src", "'self'").
+		Add("script-src", "'self'", CSPNonceSource).
+		Add("upgrade-insecure-requests").
+		String()
+	mw := Middlewares.SecurityHeadersWithCfg(cfg)
+
+	nonces := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		z := &Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)}
+
+		var nonce string
+		mw(func(z *Z) { nonce = z.CSPNonce() })(z)
+
+		if nonce == "" {
+			t.Fatal("Expected a CSP nonce for the request")
+		}
+		want := "default-src 'self'; script-src 'self' 'nonce-" + nonce + "'; upgrade-insecure-requests"
+		if got := rr.Header().Get("Content-Security-Policy"); got != want {
+			t.Errorf("Expected CSP %q, got %q", want, got)
+		}
+		nonces[nonce] = true
+	}
+	if len(nonces) != 2 {
+		t.Error("Expected a fresh nonce per request")
+	}
+}
+
+func TestSecurityHeadersMiddleware_ReportOnly(t *testing.T) {
+	mw := Middlewares.SecurityHeadersWithCfg(SecurityHeadersConfig{
+		ContentSecurityPolicy: "default-src 'self'",
+		CSPReportOnly:         true,
+		CSPReportURI:          "/csp-report",
+	})
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)}
+	mw(func(z *Z) {})(z)
+
+	if rr.Header().Get("Content-Security-Policy") != "" {
+		t.Error("Enforcing CSP should not be sent in report-only mode")
+	}
+	if got := rr.Header().Get("Content-Security-Policy-Report-Only"); got != "default-src 'self'; report-uri /csp-report; report-to csp-endpoint" {
+		t.Errorf("Unexpected report-only policy %q", got)
+	}
+	if got := rr.Header().Get("Reporting-Endpoints"); got != `csp-endpoint="/csp-report"` {
+		t.Errorf("Unexpected Reporting-Endpoints %q", got)
+	}
+}
+
+func TestSecurityHeadersMiddleware_PerRouteOverride(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.SecurityHeaders())
+
+	embed := DefaultSecurityHeadersConfig()
+	embed.FrameOptions = ""
+	embed.ContentSecurityPolicy = "frame-ancestors https://partner.example.com"
+
+	app.GET("/widget", func(z *Z) {}, Middlewares.SecurityHeadersWithCfg(embed))
+	app.GET("/raw", func(z *Z) {}, Middlewares.DisableSecurityHeaders())
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/widget", nil))
+	if rr.Header().Get("X-Frame-Options") != "" {
+		t.Error("Route override should drop X-Frame-Options")
+	}
+	if rr.Header().Get("Content-Security-Policy") != "frame-ancestors https://partner.example.com" {
+		t.Errorf("Route override CSP not applied: %q", rr.Header().Get("Content-Security-Policy"))
+	}
+
+	rr = httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/raw", nil))
+	for _, name := range securityHeaderNames {
+		if rr.Header().Get(name) != "" {
+			t.Errorf("Expected %s to be removed on disabled route", name)
+		}
+	}
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	var reports []CSPReport
+	handler := CSPReportHandler(func(z *Z, report CSPReport) { reports = append(reports, report) })
+
+	legacy := `{"csp-report":{"document-uri":"https://example.com/","blocked-uri":"https://evil.com/x.js","violated-directive":"script-src"}}`
+	req := httptest.NewRequest("POST", "/csp-report", strings.NewReader(legacy))
+	req.Header.Set("Content-Type", "application/csp-report")
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	modern := `[{"type":"csp-violation","body":{"documentURL":"https://example.com/","blockedURL":"inline","effectiveDirective":"script-src-elem"}},{"type":"deprecation","body":{}}]`
+	req = httptest.NewRequest("POST", "/csp-report", strings.NewReader(modern))
+	req.Header.Set("Content-Type", "application/reports+json")
+	handler(&Z{rw: httptest.NewRecorder(), r: req})
+
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %d", len(reports))
+	}
+	if reports[0].BlockedURI != "https://evil.com/x.js" || reports[0].ViolatedDirective != "script-src" {
+		t.Errorf("Unexpected legacy report %+v", reports[0])
+	}
+	if reports[1].BlockedURI != "inline" || reports[1].EffectiveDirective != "script-src-elem" {
+		t.Errorf("Unexpected reporting API report %+v", reports[1])
+	}
+
+	req = httptest.NewRequest("POST", "/csp-report", strings.NewReader("not json"))
+	rr = httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid report, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
 func TestTimeoutMiddleware(t *testing.T) {
 	req := httptest.NewRequest("GET", "/", nil)
 	rr := httptest.NewRecorder()
@@ -394,3 +939,174 @@ func TestTimeoutMiddleware_Default_NoTimeout(t *testing.T) {
 		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
 	}
 }
+
+func TestLoggingMiddleware_TruncatesBodies(t *testing.T) {
+	var logOutput bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logOutput, nil)))
+
+	req := httptest.NewRequest("POST", "/truncate", strings.NewReader("abcdefghij"))
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	var seen string
+	handler := func(z *Z) {
+		b, _ := io.ReadAll(z.r.Body)
+		seen = string(b)
+		z.String(http.StatusOK, "0123456789")
+	}
+
+	Middlewares.LoggingWithCfg(LoggingConfig{LogRequestBody: true, LogResponseBody: true, MaxBodyBytes: 4})(handler)(z)
+
+	if seen != "abcdefghij" {
+		t.Errorf("handler should see the full request body, got %q", seen)
+	}
+	if rr.Body.String() != "0123456789" {
+		t.Errorf("client should receive the full response body, got %q", rr.Body.String())
+	}
+	logStr := logOutput.String()
+	for _, want := range []string{`"request_body":"abcd"`, `"request_body_truncated":true`, `"response_body":"0123"`, `"response_body_truncated":true`} {
+		if !strings.Contains(logStr, want) {
+			t.Errorf("Log output should contain %s, got: %s", want, logStr)
+		}
+	}
+}
+
+func TestLoggingMiddleware_DoesNotBufferResponseWhenDisabled(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	var buffered bool
+	handler := func(z *Z) {
+		z.String(http.StatusOK, "not buffered")
+		buffered = z.rw.(*responseWriter).body != nil
+	}
+
+	Middlewares.Logging()(handler)(z)
+
+	if buffered {
+		t.Error("response body should not be buffered when LogResponseBody is false")
+	}
+}
+
+func TestLoggingMiddleware_SkipsBinaryContentTypes(t *testing.T) {
+	var logOutput bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logOutput, nil)))
+
+	req := httptest.NewRequest("GET", "/image", nil)
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	handler := func(z *Z) {
+		z.SetHeader("Content-Type", "image/png")
+		z.String(http.StatusOK, "\x89PNG binary")
+	}
+
+	Middlewares.LoggingWithCfg(LoggingConfig{LogResponseBody: true})(handler)(z)
+
+	logStr := logOutput.String()
+	if strings.Contains(logStr, "PNG binary") {
+		t.Errorf("binary body should not be logged, got: %s", logStr)
+	}
+	if !strings.Contains(logStr, `"response_body":"[omitted image/png]"`) {
+		t.Errorf("expected omitted marker, got: %s", logStr)
+	}
+}
+
+func TestLoggingMiddleware_RedactsJSONAndHeaders(t *testing.T) {
+	var logOutput bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logOutput, nil)))
+
+	body := `{"user":{"name":"bob","password":"hunter2"},"items":[{"id":1,"card":"4111"}]}`
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	handler := func(z *Z) {
+		z.SetCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+		z.JSON(http.StatusOK, map[string]string{"access_token": "tok-123", "status": "ok"})
+	}
+
+	Middlewares.LoggingWithCfg(LoggingConfig{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		LogHeaders:      true,
+		RedactJSONPaths: append([]string{"$.items.card"}, DefaultRedactJSONPaths...),
+	})(handler)(z)
+
+	logStr := logOutput.String()
+	for _, secret := range []string{"hunter2", "4111", "secret-token", "s3cr3t", "tok-123"} {
+		if strings.Contains(logStr, secret) {
+			t.Errorf("Log output leaked %q: %s", secret, logStr)
+		}
+	}
+	for _, want := range []string{`\"name\":\"bob\"`, `\"status\":\"ok\"`, `"Authorization":"[REDACTED]"`, `"Set-Cookie":"[REDACTED]"`} {
+		if !strings.Contains(logStr, want) {
+			t.Errorf("Log output should contain %s, got: %s", want, logStr)
+		}
+	}
+}
+
+func TestLoggingMiddleware_RedactsFormBodies(t *testing.T) {
+	var logOutput bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logOutput, nil)))
+
+	req := httptest.NewRequest("POST", "/form", strings.NewReader("user=bob&password=hunter2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.LoggingWithCfg(LoggingConfig{LogRequestBody: true})(func(z *Z) { z.Ok("ok") })(z)
+
+	logStr := logOutput.String()
+	if strings.Contains(logStr, "hunter2") {
+		t.Errorf("Log output leaked form password: %s", logStr)
+	}
+	if !strings.Contains(logStr, "user=bob") {
+		t.Errorf("Log output should keep non-sensitive form fields: %s", logStr)
+	}
+}
+
+func TestLoggingMiddleware_RedactsBodiesWithoutContentType(t *testing.T) {
+	var logOutput bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logOutput, nil)))
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"user":"bob","password":"hunter2"}`))
+	rr := httptest.NewRecorder()
+	z := &Z{rw: rr, r: req}
+
+	Middlewares.LoggingWithCfg(LoggingConfig{LogRequestBody: true})(func(z *Z) { z.Ok("ok") })(z)
+
+	logStr := logOutput.String()
+	if strings.Contains(logStr, "hunter2") {
+		t.Errorf("Log output leaked password from body without Content-Type: %s", logStr)
+	}
+	if !strings.Contains(logStr, `\"user\":\"bob\"`) {
+		t.Errorf("Log output should keep non-sensitive fields: %s", logStr)
+	}
+}
+
+func TestMatchJSONPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    []string
+		want    bool
+	}{
+		{"$..password", []string{"password"}, true},
+		{"$..password", []string{"a", "b", "password"}, true},
+		{"$.user.password", []string{"user", "password"}, true},
+		{"$.user.password", []string{"password"}, false},
+		{"$.items[*].card", []string{"items", "card"}, true},
+		{"$.*.token", []string{"anything", "token"}, true},
+		{"$.*.token", []string{"token"}, false},
+	}
+	for _, c := range cases {
+		if got := matchJSONPath(parseJSONPath(c.pattern), c.path); got != c.want {
+			t.Errorf("matchJSONPath(%q, %v) = %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ParamError struct {
+	In       string `json:"in"`
+	Name     string `json:"param"`
+	Value    string `json:"value"`
+	Expected string `json:"expected"`
+}
+
+func (e *ParamError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("missing %s param %q: expected %s", e.In, e.Name, e.Expected)
+	}
+	return fmt.Sprintf("invalid %s param %q: expected %s", e.In, e.Name, e.Expected)
+}
+
+type paramAbort struct {
+	err *ParamError
+}
+
+func abortOnParamError(handler HandlerFunc) HandlerFunc {
+	return func(z *Z) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				abort, ok := recovered.(paramAbort)
+				if !ok {
+					panic(recovered)
+				}
+				z.JSON(http.StatusBadRequest, map[string]any{
+					"error":    "invalid_param",
+					"message":  abort.err.Error(),
+					"in":       abort.err.In,
+					"param":    abort.err.Name,
+					"expected": abort.err.Expected,
+				})
+			}
+		}()
+		handler(z)
+	}
+}
+
+func must[T any](value T, err error) T {
+	if err != nil {
+		var paramErr *ParamError
+		if errors.As(err, &paramErr) {
+			panic(paramAbort{err: paramErr})
+		}
+		panic(err)
+	}
+	return value
+}
+
+func (z *Z) PathInt(key string) (int, error) {
+	value := z.r.PathValue(key)
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &ParamError{In: "path", Name: key, Value: value, Expected: "integer"}
+	}
+	return n, nil
+}
+
+func (z *Z) PathInt64(key string) (int64, error) {
+	value := z.r.PathValue(key)
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &ParamError{In: "path", Name: key, Value: value, Expected: "integer"}
+	}
+	return n, nil
+}
+
+func (z *Z) PathUUID(key string) (string, error) {
+	value := z.r.PathValue(key)
+	if !isUUID(value) {
+		return "", &ParamError{In: "path", Name: key, Value: value, Expected: "uuid"}
+	}
+	return strings.ToLower(value), nil
+}
+
+func (z *Z) QueryInt(key string, def int) (int, error) {
+	value := z.r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def, &ParamError{In: "query", Name: key, Value: value, Expected: "integer"}
+	}
+	return n, nil
+}
+
+func (z *Z) QueryBool(key string) (bool, error) {
+	value := z.r.URL.Query().Get(key)
+	switch strings.ToLower(value) {
+	case "", "0", "f", "false", "no", "off":
+		return false, nil
+	case "1", "t", "true", "yes", "on":
+		return true, nil
+	}
+	return false, &ParamError{In: "query", Name: key, Value: value, Expected: "boolean"}
+}
+
+func (z *Z) QueryTime(key, layout string) (time.Time, error) {
+	value := z.r.URL.Query().Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, &ParamError{In: "query", Name: key, Value: value, Expected: "time in layout " + layout}
+	}
+	return t, nil
+}
+
+func (z *Z) QueryList(key string) []string {
+	var list []string
+	for _, value := range z.r.URL.Query()[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+func (z *Z) MustPathInt(key string) int {
+	return must(z.PathInt(key))
+}
+
+func (z *Z) MustPathInt64(key string) int64 {
+	return must(z.PathInt64(key))
+}
+
+func (z *Z) MustPathUUID(key string) string {
+	return must(z.PathUUID(key))
+}
+
+func (z *Z) MustQueryInt(key string, def int) int {
+	return must(z.QueryInt(key, def))
+}
+
+func (z *Z) MustQueryBool(key string) bool {
+	return must(z.QueryBool(key))
+}
+
+func (z *Z) MustQueryTime(key, layout string) time.Time {
+	return must(z.QueryTime(key, layout))
+}
+
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i, c := range value {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
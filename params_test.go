@@ -0,0 +1,102 @@
+package z
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPathParams(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.SetPathValue("id", "42")
+	req.SetPathValue("big", "9000000000")
+	req.SetPathValue("uuid", "0190B5C6-1E2A-7C3D-8E4F-5A6B7C8D9E0F")
+	req.SetPathValue("bad", "abc")
+	z := &Z{r: req}
+
+	if n, err := z.PathInt("id"); err != nil || n != 42 {
+		t.Errorf("PathInt = %d, %v", n, err)
+	}
+	if n, err := z.PathInt64("big"); err != nil || n != 9000000000 {
+		t.Errorf("PathInt64 = %d, %v", n, err)
+	}
+	if id, err := z.PathUUID("uuid"); err != nil || id != "0190b5c6-1e2a-7c3d-8e4f-5a6b7c8d9e0f" {
+		t.Errorf("PathUUID = %q, %v", id, err)
+	}
+
+	var paramErr *ParamError
+	if _, err := z.PathInt("bad"); !errors.As(err, &paramErr) || paramErr.Name != "bad" || paramErr.In != "path" {
+		t.Errorf("Expected ParamError, got %v", err)
+	}
+	if _, err := z.PathUUID("id"); err == nil {
+		t.Error("Expected invalid UUID error")
+	}
+	if _, err := z.PathInt("missing"); err == nil || !strings.Contains(err.Error(), "missing path param") {
+		t.Errorf("Expected missing param error, got %v", err)
+	}
+}
+
+func TestQueryParams(t *testing.T) {
+	z := &Z{r: httptest.NewRequest("GET", "/?page=3&bad=x&flag=yes&off=0&since=2024-05-01&tags=a,b&tags=c,+,d", nil)}
+
+	if n, err := z.QueryInt("page", 1); err != nil || n != 3 {
+		t.Errorf("QueryInt = %d, %v", n, err)
+	}
+	if n, err := z.QueryInt("limit", 20); err != nil || n != 20 {
+		t.Errorf("Expected default, got %d, %v", n, err)
+	}
+	if _, err := z.QueryInt("bad", 1); err == nil {
+		t.Error("Expected QueryInt error")
+	}
+	if b, err := z.QueryBool("flag"); err != nil || !b {
+		t.Errorf("QueryBool(flag) = %v, %v", b, err)
+	}
+	if b, err := z.QueryBool("off"); err != nil || b {
+		t.Errorf("QueryBool(off) = %v, %v", b, err)
+	}
+	if _, err := z.QueryBool("bad"); err == nil {
+		t.Error("Expected QueryBool error")
+	}
+	if ts, err := z.QueryTime("since", time.DateOnly); err != nil || !ts.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("QueryTime = %v, %v", ts, err)
+	}
+	if ts, err := z.QueryTime("until", time.DateOnly); err != nil || !ts.IsZero() {
+		t.Errorf("Expected zero time, got %v, %v", ts, err)
+	}
+	if list := z.QueryList("tags"); strings.Join(list, "|") != "a|b|c|d" {
+		t.Errorf("QueryList = %v", list)
+	}
+}
+
+func TestMustParamsAbortWith400(t *testing.T) {
+	app := New()
+	var reached bool
+	app.GET("/users/{id}", func(z *Z) {
+		id := z.MustPathInt("id")
+		limit := z.MustQueryInt("limit", 10)
+		reached = true
+		z.OkJSON(map[string]int{"id": id, "limit": limit})
+	}, Middlewares.Recovery())
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/users/7?limit=5", nil))
+	if rr.Code != http.StatusOK || !reached {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	for _, target := range []string{"/users/abc", "/users/7?limit=many"} {
+		reached = false
+		rr = httptest.NewRecorder()
+		app.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+
+		var body map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if rr.Code != http.StatusBadRequest || reached || body["error"] != "invalid_param" || body["expected"] != "integer" {
+			t.Fatalf("%s: expected structured 400, got %d %v", target, rr.Code, body)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package z
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+func (app *App) SetTrustedProxies(proxies ...string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	app.trustedProxies = prefixes
+	return nil
+}
+
+func (app *App) isTrustedProxy(addr netip.Addr) bool {
+	if app == nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type forwardedHop struct {
+	addr  netip.Addr
+	proto string
+	host  string
+}
+
+func (app *App) SetTrustedProxyHeader(header string) error {
+	header = http.CanonicalHeaderKey(header)
+	switch header {
+	case "Forwarded", "X-Forwarded-For", "X-Real-Ip":
+		app.trustedProxyHeader = header
+		return nil
+	}
+	return fmt.Errorf("unsupported trusted proxy header %q", header)
+}
+
+func (z *Z) forwardedHops() (hops []forwardedHop, lists bool) {
+	switch z.app.trustedProxyHeader {
+	case "Forwarded":
+		return z.forwardedHeaderHops(), false
+	case "X-Forwarded-For":
+		return z.xForwardedForHops(), true
+	case "X-Real-Ip":
+		return z.realIPHops(), true
+	}
+	if hops := z.xForwardedForHops(); hops != nil {
+		return hops, true
+	}
+	if hops := z.realIPHops(); hops != nil {
+		return hops, true
+	}
+	return z.forwardedHeaderHops(), false
+}
+
+func (z *Z) forwardedHeaderHops() (hops []forwardedHop) {
+	for _, value := range z.r.Header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			var hop forwardedHop
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(val, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					hop.addr = parseForwardedAddr(val)
+				case "proto":
+					hop.proto = strings.ToLower(val)
+				case "host":
+					hop.host = val
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+func (z *Z) xForwardedForHops() (hops []forwardedHop) {
+	for _, value := range z.r.Header.Values("X-Forwarded-For") {
+		for _, v := range strings.Split(value, ",") {
+			hops = append(hops, forwardedHop{addr: parseForwardedAddr(strings.TrimSpace(v))})
+		}
+	}
+	return hops
+}
+
+func (z *Z) realIPHops() []forwardedHop {
+	if realIP := z.r.Header.Get("X-Real-IP"); realIP != "" {
+		return []forwardedHop{{addr: parseForwardedAddr(strings.TrimSpace(realIP))}}
+	}
+	return nil
+}
+
+func (z *Z) forwardedListValue(name string, trustedHops int) string {
+	var values []string
+	for _, value := range z.r.Header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	i := len(values) - trustedHops
+	if i < 0 {
+		i = len(values) - 1
+	}
+	return values[i]
+}
+
+func parseForwardedAddr(value string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (z *Z) clientHop() (forwardedHop, bool) {
+	peer := parseForwardedAddr(remoteIP(z.r))
+	if !z.app.isTrustedProxy(peer) {
+		return forwardedHop{addr: peer}, false
+	}
+
+	hops, lists := z.forwardedHops()
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !hops[i].addr.IsValid() {
+			break
+		}
+		trusted := z.app.isTrustedProxy(hops[i].addr)
+		if !trusted || i == 0 {
+			hop := hops[i]
+			if lists {
+				trustedHops := len(hops) - i
+				if trusted {
+					trustedHops = 1
+				}
+				hop.proto = strings.ToLower(z.forwardedListValue("X-Forwarded-Proto", trustedHops))
+				hop.host = z.forwardedListValue("X-Forwarded-Host", trustedHops)
+			}
+			return hop, true
+		}
+	}
+	return forwardedHop{addr: peer}, false
+}
+
+func (z *Z) ClientIP() string {
+	hop, _ := z.clientHop()
+	if !hop.addr.IsValid() {
+		return remoteIP(z.r)
+	}
+	return hop.addr.String()
+}
+
+func (z *Z) Scheme() string {
+	if hop, forwarded := z.clientHop(); forwarded && (hop.proto == "http" || hop.proto == "https") {
+		return hop.proto
+	}
+	if z.r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func (z *Z) Host() string {
+	if hop, forwarded := z.clientHop(); forwarded && hop.host != "" {
+		return hop.host
+	}
+	return z.r.Host
+}
@@ -0,0 +1,171 @@
+package z
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func newProxyTestZ(t *testing.T, remoteAddr string, headers map[string]string) *Z {
+	t.Helper()
+	app := New()
+	if err := app.SetTrustedProxies("10.0.0.0/8", "192.168.1.1", "2001:db8::/32"); err != nil {
+		t.Fatalf("SetTrustedProxies failed: %v", err)
+	}
+	req := httptest.NewRequest("GET", "http://internal.local/", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return &Z{rw: httptest.NewRecorder(), r: req, app: app}
+}
+
+func TestSetTrustedProxies_Invalid(t *testing.T) {
+	app := New()
+	for _, p := range []string{"not-an-ip", "10.0.0.0/99"} {
+		if err := app.SetTrustedProxies(p); err == nil {
+			t.Errorf("Expected error for %q", p)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"no proxy", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted peer ignores headers", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.5"},
+		{"trusted peer x-forwarded-for", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"skips trusted hops", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.7, 10.1.1.1"}, "198.51.100.7"},
+		{"all trusted returns leftmost", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "10.9.9.9, 10.1.1.1"}, "10.9.9.9"},
+		{"x-real-ip", "192.168.1.1:80", map[string]string{"X-Real-IP": "198.51.100.8"}, "198.51.100.8"},
+		{"forwarded header", "10.0.0.2:1234", map[string]string{"Forwarded": `for=198.51.100.9;proto=https, for="[2001:db8::1]:4711"`}, "198.51.100.9"},
+		{"x-forwarded-for wins over client forwarded", "10.0.0.1:1234", map[string]string{"Forwarded": "for=6.6.6.6", "X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+		{"forwarded ipv6", "10.0.0.2:1234", map[string]string{"Forwarded": `for="[2001:db9::1]:4711"`}, "2001:db9::1"},
+		{"invalid hop falls back to peer", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "garbage"}, "10.0.0.2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			z := newProxyTestZ(t, c.remote, c.headers)
+			if got := z.ClientIP(); got != c.want {
+				t.Errorf("Expected client IP %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSetTrustedProxyHeader(t *testing.T) {
+	headers := map[string]string{"Forwarded": "for=198.51.100.9", "X-Forwarded-For": "203.0.113.9", "X-Real-IP": "192.0.2.4"}
+	cases := map[string]string{
+		"forwarded":       "198.51.100.9",
+		"X-Forwarded-For": "203.0.113.9",
+		"x-real-ip":       "192.0.2.4",
+	}
+	for header, want := range cases {
+		z := newProxyTestZ(t, "10.0.0.1:1234", headers)
+		if err := z.app.SetTrustedProxyHeader(header); err != nil {
+			t.Fatalf("SetTrustedProxyHeader(%q) failed: %v", header, err)
+		}
+		if got := z.ClientIP(); got != want {
+			t.Errorf("%s: expected client IP %q, got %q", header, want, got)
+		}
+	}
+
+	z := newProxyTestZ(t, "10.0.0.1:1234", map[string]string{"Forwarded": "for=6.6.6.6"})
+	z.app.SetTrustedProxyHeader("X-Forwarded-For")
+	if got := z.ClientIP(); got != "10.0.0.1" {
+		t.Errorf("Only the configured header should be trusted, got %q", got)
+	}
+	if err := New().SetTrustedProxyHeader("X-Client-IP"); err == nil {
+		t.Error("Expected error for unsupported header")
+	}
+}
+
+func TestClientIP_NoApp(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	z := &Z{r: req}
+	if got := z.ClientIP(); got != "10.0.0.2" {
+		t.Errorf("Without trusted proxies headers must be ignored, got %q", got)
+	}
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	z := newProxyTestZ(t, "10.0.0.2:1234", map[string]string{
+		"X-Forwarded-For":   "198.51.100.7",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "shop.example.com",
+	})
+	if z.Scheme() != "https" || z.Host() != "shop.example.com" {
+		t.Errorf("Expected forwarded scheme and host, got %q %q", z.Scheme(), z.Host())
+	}
+
+	z = newProxyTestZ(t, "10.0.0.2:1234", map[string]string{
+		"Forwarded": `for=198.51.100.9;proto=https;host="api.example.com"`,
+	})
+	if z.Scheme() != "https" || z.Host() != "api.example.com" {
+		t.Errorf("Expected Forwarded scheme and host, got %q %q", z.Scheme(), z.Host())
+	}
+
+	z = newProxyTestZ(t, "203.0.113.5:1234", map[string]string{
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "spoofed.example.com",
+	})
+	if z.Scheme() != "http" || z.Host() != "internal.local" {
+		t.Errorf("Untrusted peers must not influence scheme and host, got %q %q", z.Scheme(), z.Host())
+	}
+
+	z.r.TLS = &tls.ConnectionState{}
+	if z.Scheme() != "https" {
+		t.Errorf("Expected https for TLS connections, got %q", z.Scheme())
+	}
+}
+
+func TestSchemeAndHost_MultipleProxies(t *testing.T) {
+	cases := []struct {
+		name      string
+		headers   map[string]string
+		wantProto string
+		wantHost  string
+	}{
+		{"single values", map[string]string{
+			"X-Forwarded-For":   "1.2.3.4, 10.0.0.1",
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "shop.example.com",
+		}, "https", "shop.example.com"},
+		{"appended values", map[string]string{
+			"X-Forwarded-For":   "1.2.3.4, 10.0.0.1",
+			"X-Forwarded-Proto": "https, http",
+			"X-Forwarded-Host":  "shop.example.com, edge.internal",
+		}, "https", "shop.example.com"},
+		{"spoofed values ignored", map[string]string{
+			"X-Forwarded-For":   "6.6.6.6, 1.2.3.4, 10.0.0.1",
+			"X-Forwarded-Proto": "http, https, http",
+			"X-Forwarded-Host":  "evil.example, shop.example.com, edge.internal",
+		}, "https", "shop.example.com"},
+		{"all hops trusted", map[string]string{
+			"X-Forwarded-For":   "10.9.9.9, 10.0.0.1",
+			"X-Forwarded-Proto": "http, https",
+			"X-Forwarded-Host":  "edge.internal, shop.example.com",
+		}, "https", "shop.example.com"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			z := newProxyTestZ(t, "10.0.0.2:1234", c.headers)
+			if z.Scheme() != c.wantProto || z.Host() != c.wantHost {
+				t.Errorf("Expected %q %q, got %q %q", c.wantProto, c.wantHost, z.Scheme(), z.Host())
+			}
+		})
+	}
+}
+
+func TestClientIP_UsedByRateLimit(t *testing.T) {
+	z := newProxyTestZ(t, "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"})
+	if got := RateLimitByIP()(z); got != "ip:198.51.100.7" {
+		t.Errorf("Rate limiting should key on the real client IP, got %q", got)
+	}
+}
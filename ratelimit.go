@@ -0,0 +1,287 @@
+package z
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	TokenBucket RateLimitAlgorithm = iota
+	SlidingWindow
+)
+
+type RateLimitRule struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	Burst     int
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+type RateLimitConfig struct {
+	Algorithm    RateLimitAlgorithm
+	Limit        int
+	Window       time.Duration
+	Burst        int
+	KeyFunc      func(z *Z) string
+	KeyPrefix    string
+	Store        RateLimitStore
+	Skip         func(z *Z) bool
+	LimitReached HandlerFunc
+}
+
+func (middlewaresRegistry) RateLimit() MiddlewareFunc {
+	return Middlewares.RateLimitWithCfg(RateLimitConfig{Limit: 100, Window: time.Minute})
+}
+
+func (middlewaresRegistry) RateLimitWithCfg(cfg RateLimitConfig) MiddlewareFunc {
+	if cfg.Limit <= 0 {
+		cfg.Limit = 100
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = RateLimitByIP()
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	if cfg.LimitReached == nil {
+		cfg.LimitReached = func(z *Z) {
+			z.String(http.StatusTooManyRequests, "Too Many Requests")
+		}
+	}
+
+	rule := RateLimitRule{
+		Algorithm: cfg.Algorithm,
+		Limit:     cfg.Limit,
+		Window:    cfg.Window,
+		Burst:     cfg.Burst,
+	}
+	policy := fmt.Sprintf("%d;w=%d", cfg.Limit, int(math.Ceil(cfg.Window.Seconds())))
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			if cfg.Skip != nil && cfg.Skip(z) {
+				next(z)
+				return
+			}
+
+			key := cfg.KeyPrefix + cfg.KeyFunc(z)
+			result, err := cfg.Store.Allow(z.r.Context(), key, rule)
+			if err != nil {
+				slog.Error("Rate limit store failed", "err", err)
+				next(z)
+				return
+			}
+
+			header := z.rw.Header()
+			header.Set("RateLimit-Policy", policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				cfg.LimitReached(z)
+				return
+			}
+			next(z)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func RateLimitByIP() func(z *Z) string {
+	return func(z *Z) string {
+		return "ip:" + z.ClientIP()
+	}
+}
+
+func RateLimitByHeader(name string) func(z *Z) string {
+	return func(z *Z) string {
+		if value := z.r.Header.Get(name); value != "" {
+			return "header:" + value
+		}
+		return "ip:" + z.ClientIP()
+	}
+}
+
+func RateLimitByUser(user func(z *Z) string) func(z *Z) string {
+	if user == nil {
+		user = func(z *Z) string {
+			if principal := z.Principal(); principal != nil {
+				return principal.Subject
+			}
+			return ""
+		}
+	}
+	return func(z *Z) string {
+		if id := user(z); id != "" {
+			return "user:" + id
+		}
+		return "ip:" + z.ClientIP()
+	}
+}
+
+func RateLimitByRoute() func(z *Z) string {
+	return func(z *Z) string {
+		return "route:" + z.r.Method + " " + z.RoutePattern()
+	}
+}
+
+const rateLimitShards = 32
+
+type rateLimitEntry struct {
+	tokens      float64
+	last        time.Time
+	windowStart time.Time
+	prevCount   int
+	currCount   int
+	expires     time.Time
+}
+
+type rateLimitShard struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	nextSweep time.Time
+}
+
+type MemoryRateLimitStore struct {
+	shards [rateLimitShards]*rateLimitShard
+	now    func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{now: time.Now}
+	for i := range s.shards {
+		s.shards[i] = &rateLimitShard{entries: map[string]*rateLimitEntry{}}
+	}
+	return s
+}
+
+func (s *MemoryRateLimitStore) shard(key string) *rateLimitShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%rateLimitShards]
+}
+
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	now := s.now()
+	shard := s.shard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if now.After(shard.nextSweep) {
+		for k, e := range shard.entries {
+			if now.After(e.expires) {
+				delete(shard.entries, k)
+			}
+		}
+		shard.nextSweep = now.Add(rule.Window)
+	}
+
+	entry, ok := shard.entries[key]
+	if !ok {
+		entry = &rateLimitEntry{tokens: -1}
+		shard.entries[key] = entry
+	}
+
+	switch rule.Algorithm {
+	case SlidingWindow:
+		return entry.slidingWindow(now, rule), nil
+	default:
+		return entry.tokenBucket(now, rule), nil
+	}
+}
+
+func (s *MemoryRateLimitStore) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+func (e *rateLimitEntry) tokenBucket(now time.Time, rule RateLimitRule) RateLimitResult {
+	capacity := float64(rule.Burst)
+	if capacity <= 0 {
+		capacity = float64(rule.Limit)
+	}
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+
+	if e.tokens < 0 {
+		e.tokens = capacity
+	} else {
+		e.tokens = math.Min(capacity, e.tokens+now.Sub(e.last).Seconds()*rate)
+	}
+	e.last = now
+
+	result := RateLimitResult{Limit: int(capacity)}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - e.tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(e.tokens)
+	result.Reset = time.Duration((capacity - e.tokens) / rate * float64(time.Second))
+	e.expires = now.Add(result.Reset)
+	return result
+}
+
+func (e *rateLimitEntry) slidingWindow(now time.Time, rule RateLimitRule) RateLimitResult {
+	start := now.Truncate(rule.Window)
+	switch {
+	case start.Equal(e.windowStart):
+	case start.Sub(e.windowStart) == rule.Window:
+		e.prevCount, e.currCount = e.currCount, 0
+		e.windowStart = start
+	default:
+		e.prevCount, e.currCount = 0, 0
+		e.windowStart = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	estimated := int(math.Floor(float64(e.prevCount)*weight)) + e.currCount
+
+	result := RateLimitResult{Limit: rule.Limit, Reset: rule.Window - elapsed}
+	if estimated < rule.Limit {
+		e.currCount++
+		estimated++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = result.Reset
+	}
+	result.Remaining = max(rule.Limit-estimated, 0)
+	e.expires = start.Add(2 * rule.Window)
+	return result
+}
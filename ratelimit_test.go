@@ -0,0 +1,241 @@
+package z
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestRateLimitStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestMemoryRateLimitStore_TokenBucket(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	rule := RateLimitRule{Algorithm: TokenBucket, Limit: 2, Window: time.Second}
+
+	for i := 0; i < 2; i++ {
+		if res, _ := store.Allow(context.Background(), "k", rule); !res.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	res, _ := store.Allow(context.Background(), "k", rule)
+	if res.Allowed {
+		t.Fatal("third request should be limited")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected RetryAfter 500ms, got %v", res.RetryAfter)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if res, _ := store.Allow(context.Background(), "k", rule); !res.Allowed {
+		t.Error("a token should have been refilled")
+	}
+}
+
+func TestMemoryRateLimitStore_TokenBucketBurst(t *testing.T) {
+	store, _ := newTestRateLimitStore()
+	rule := RateLimitRule{Algorithm: TokenBucket, Limit: 1, Window: time.Second, Burst: 5}
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if res, _ := store.Allow(context.Background(), "k", rule); res.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("Expected burst of 5, got %d", allowed)
+	}
+}
+
+func TestMemoryRateLimitStore_SlidingWindow(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	rule := RateLimitRule{Algorithm: SlidingWindow, Limit: 4, Window: time.Minute}
+
+	for i := 0; i < 4; i++ {
+		if res, _ := store.Allow(context.Background(), "k", rule); !res.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if res, _ := store.Allow(context.Background(), "k", rule); res.Allowed || res.Remaining != 0 {
+		t.Fatalf("fifth request should be limited, got %+v", res)
+	}
+
+	clock.Advance(time.Minute + 30*time.Second)
+	allowed := 0
+	for i := 0; i < 4; i++ {
+		if res, _ := store.Allow(context.Background(), "k", rule); res.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Half of the previous window should still count, expected 2 allowed, got %d", allowed)
+	}
+
+	clock.Advance(5 * time.Minute)
+	if res, _ := store.Allow(context.Background(), "k", rule); !res.Allowed || res.Remaining != 3 {
+		t.Errorf("Expected a fresh window, got %+v", res)
+	}
+}
+
+func TestMemoryRateLimitStore_Expiry(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	rule := RateLimitRule{Limit: 10, Window: time.Second}
+
+	store.Allow(context.Background(), "a", rule)
+	clock.Advance(time.Hour)
+
+	shard := store.shard("a")
+	other := "b"
+	for i := 0; store.shard(other) != shard; i++ {
+		other = fmt.Sprintf("b%d", i)
+	}
+	store.Allow(context.Background(), other, rule)
+
+	if _, ok := shard.entries["a"]; ok {
+		t.Error("Expired entries should be swept")
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected 1 live entry, got %d", store.Len())
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	store, _ := newTestRateLimitStore()
+	mw := Middlewares.RateLimitWithCfg(RateLimitConfig{Limit: 1, Window: time.Minute, Store: store})
+
+	handler := mw(func(z *Z) { z.Ok("ok") })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected first request to pass, got %d", rr.Code)
+	}
+	if rr.Header().Get("RateLimit-Limit") != "1" || rr.Header().Get("RateLimit-Remaining") != "0" || rr.Header().Get("RateLimit-Policy") != "1;w=60" {
+		t.Errorf("Unexpected rate limit headers: %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	handler(&Z{rw: rr, r: req})
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	other := httptest.NewRequest("GET", "/", nil)
+	other.RemoteAddr = "10.0.0.2:1234"
+	rr = httptest.NewRecorder()
+	handler(&Z{rw: rr, r: other})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Other clients should have their own bucket, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitMiddleware_KeyFuncs(t *testing.T) {
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Api-Key", "abc")
+	z := &Z{r: req, route: &Route{Pattern: "/users/{id}"}}
+
+	cases := map[string]func(z *Z) string{
+		"ip:10.0.0.1":           RateLimitByIP(),
+		"header:abc":            RateLimitByHeader("X-Api-Key"),
+		"user:alice":            RateLimitByUser(func(z *Z) string { return "alice" }),
+		"route:GET /users/{id}": RateLimitByRoute(),
+	}
+	for want, keyFunc := range cases {
+		if got := keyFunc(z); got != want {
+			t.Errorf("Expected key %q, got %q", want, got)
+		}
+	}
+	if got := RateLimitByUser(func(z *Z) string { return "" })(z); got != "ip:10.0.0.1" {
+		t.Errorf("Anonymous users should fall back to the client IP, got %q", got)
+	}
+	if got := RateLimitByHeader("X-Missing")(z); got != "ip:10.0.0.1" {
+		t.Errorf("Requests without the header should fall back to the client IP, got %q", got)
+	}
+}
+
+func TestRateLimitMiddleware_PerRouteLimits(t *testing.T) {
+	app := New()
+	app.GET("/cheap", func(z *Z) { z.Ok("ok") }, Middlewares.RateLimitWithCfg(RateLimitConfig{Limit: 5, Window: time.Minute}))
+	app.GET("/expensive", func(z *Z) { z.Ok("ok") }, Middlewares.RateLimitWithCfg(RateLimitConfig{Limit: 1, Window: time.Minute}))
+
+	codes := map[string][]int{}
+	for _, path := range []string{"/expensive", "/expensive", "/cheap", "/cheap"} {
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		codes[path] = append(codes[path], rr.Code)
+	}
+	if codes["/expensive"][1] != http.StatusTooManyRequests {
+		t.Errorf("Expected /expensive to be limited, got %v", codes["/expensive"])
+	}
+	if codes["/cheap"][1] != http.StatusOK {
+		t.Errorf("Expected /cheap to have its own limit, got %v", codes["/cheap"])
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Allow(context.Context, string, RateLimitRule) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store down")
+}
+
+func TestRateLimitMiddleware_StoreErrorFailsOpen(t *testing.T) {
+	mw := Middlewares.RateLimitWithCfg(RateLimitConfig{Store: failingRateLimitStore{}})
+	rr := httptest.NewRecorder()
+	called := false
+	mw(func(z *Z) { called = true })(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+
+	if !called {
+		t.Error("Requests should pass when the store fails")
+	}
+}
+
+func TestRateLimitMiddleware_SkipAndCustomResponse(t *testing.T) {
+	mw := Middlewares.RateLimitWithCfg(RateLimitConfig{
+		Limit:        1,
+		Skip:         func(z *Z) bool { return z.r.URL.Path == "/healthz" },
+		LimitReached: func(z *Z) { z.JSON(http.StatusTooManyRequests, map[string]string{"error": "slow down"}) },
+	})
+	handler := mw(func(z *Z) { z.Ok("ok") })
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler(&Z{rw: rr, r: httptest.NewRequest("GET", "/healthz", nil)})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Skipped requests should not be limited, got %d", rr.Code)
+		}
+	}
+
+	handler(&Z{rw: httptest.NewRecorder(), r: httptest.NewRequest("GET", "/", nil)})
+	rr := httptest.NewRecorder()
+	handler(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected custom JSON 429, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
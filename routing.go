@@ -1,48 +1,361 @@
 package z
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
 )
 
 type HandlerFunc func(z *Z)
 
-func (app *App) handle(method string, path string, handler HandlerFunc, routeMiddlewares ...MiddlewareFunc) {
-	finalHandler := handler
+var (
+	ErrRouteConflict = errors.New("route conflict")
+	ErrInvalidRoute  = errors.New("invalid route")
+)
+
+type Route struct {
+	Method          string
+	Host            string
+	Pattern         string
+	Version         string
+	Name            string
+	Tags            []string
+	Meta            map[string]any
+	Constraints     map[string]ParamConstraint
+	MiddlewareCount int
+	HandlerName     string
+	versioning      *Versioning
+}
+
+func (route *Route) WithName(name string) *Route {
+	route.Name = name
+	return route
+}
+
+func (route *Route) WithTags(tags ...string) *Route {
+	route.Tags = append(route.Tags, tags...)
+	return route
+}
+
+func (route *Route) WithMeta(key string, value any) *Route {
+	if route.Meta == nil {
+		route.Meta = map[string]any{}
+	}
+	route.Meta[key] = value
+	return route
+}
+
+func (route *Route) HasTag(tag string) bool {
+	return slices.Contains(route.Tags, tag)
+}
+
+func (z *Z) Route() *Route {
+	return z.route
+}
+
+func (z *Z) RoutePattern() string {
+	if z.route == nil {
+		return ""
+	}
+	return z.route.Pattern
+}
+
+func (z *Z) RouteName() string {
+	if z.route == nil {
+		return ""
+	}
+	return z.route.Name
+}
+
+func (z *Z) RouteMeta(key string) any {
+	if z.route == nil {
+		return nil
+	}
+	return z.route.Meta[key]
+}
+
+type pathRoutes struct {
+	pattern        string
+	methods        []string
+	autoOptions    bool
+	optionsHandler HandlerFunc
+}
+
+func (app *App) handle(route *Route, handler HandlerFunc, routeMiddlewares ...MiddlewareFunc) *Route {
+	route.MiddlewareCount = len(app.middlewares) + len(routeMiddlewares)
+	route.HandlerName = handlerName(handler)
+	method := route.Method
+	muxPath, constraints, err := parseRoutePattern(route.Pattern)
+	if err != nil {
+		app.routeErrors = append(app.routeErrors, fmt.Errorf("%w: %s %s: %v", ErrInvalidRoute, method, route.Pattern, err))
+		return route
+	}
+	route.Pattern, route.Constraints = muxPath, constraints
+	path := muxPath
+	finalHandler := abortOnParamError(handler)
 
 	for i := len(routeMiddlewares) - 1; i >= 0; i-- {
 		finalHandler = routeMiddlewares[i](finalHandler)
 	}
 
+	finalHandler = app.applyMiddlewares(finalHandler)
+
+	routes := app.pathRoutes(path)
+	path = routes.pattern
+	if method == http.MethodOptions && routes.autoOptions {
+		routes.optionsHandler = func(z *Z) {
+			z.route = route
+			finalHandler(z)
+		}
+		routes.methods = append(routes.methods, method)
+		app.routes = append(app.routes, route)
+		return route
+	}
+
+	if !app.register(route, finalHandler) {
+		return route
+	}
+	routes.methods = append(routes.methods, method)
+	app.routes = append(app.routes, route)
+
+	if app.autoOptions {
+		app.registerAutoOptions(path, routes)
+	}
+	return route
+}
+
+func (app *App) applyMiddlewares(handler HandlerFunc) HandlerFunc {
 	for i := len(app.middlewares) - 1; i >= 0; i-- {
-		finalHandler = app.middlewares[i](finalHandler)
+		handler = app.middlewares[i](handler)
+	}
+	return handler
+}
+
+func (app *App) register(route *Route, handler HandlerFunc) (ok bool) {
+	shape := route.Method + " " + patternShape(route.Pattern)
+	candidate := &routeCandidate{route: route, names: wildcardNames(route.Pattern), handler: handler}
+	if slot, exists := app.slots[shape]; exists {
+		for _, existing := range slot.candidates {
+			if routesConflict(existing.route, route) {
+				app.routeErrors = append(app.routeErrors, fmt.Errorf("%w: %s %s conflicts with %s %s",
+					ErrRouteConflict, route.Method, route.Host+route.Pattern, existing.route.Method, existing.route.Host+existing.route.Pattern))
+				return false
+			}
+		}
+		slot.add(candidate)
+		return true
+	}
+	slot := &routeSlot{method: route.Method, pattern: route.Pattern, names: candidate.names, candidates: []*routeCandidate{candidate}}
+	pattern := fmt.Sprintf("%s %s", route.Method, route.Pattern)
+
+	if err := validatePattern(pattern); err != nil {
+		app.routeErrors = append(app.routeErrors, fmt.Errorf("%w: %s %s: %v", ErrInvalidRoute, route.Method, route.Pattern, err))
+		return false
 	}
 
-	app.mux.HandleFunc(fmt.Sprintf("%s %s", method, path), func(w http.ResponseWriter, r *http.Request) {
-		zHandler := &Z{
-			rw: w,
-			r:  r,
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			app.routeErrors = append(app.routeErrors, fmt.Errorf("%w: %s %s: %v", ErrRouteConflict, route.Method, route.Pattern, recovered))
+			ok = false
 		}
-		finalHandler(zHandler)
+	}()
+
+	app.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		app.dispatch(slot, w, r)
 	})
+	app.slots[shape] = slot
+	return true
+}
+
+func validatePattern(pattern string) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+	http.NewServeMux().HandleFunc(pattern, func(http.ResponseWriter, *http.Request) {})
+	return nil
+}
+
+func handlerName(handler HandlerFunc) string {
+	if handler == nil {
+		return ""
+	}
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+}
+
+func (app *App) Routes() []Route {
+	routes := make([]Route, len(app.routes))
+	for i, route := range app.routes {
+		routes[i] = *route
+	}
+	return routes
+}
+
+func (app *App) Err() error {
+	return errors.Join(app.routeErrors...)
+}
+
+func (app *App) SetDebug(debug bool) {
+	app.debug = debug
+}
+
+func (app *App) PrintRoutes(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tNAME\tMIDDLEWARES\tHANDLER")
+	for _, route := range app.routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", route.Method, route.Host+displayPattern(route), route.Name, route.MiddlewareCount, route.HandlerName)
+	}
+	tw.Flush()
+}
+
+func (app *App) Listen(addr string) error {
+	if err := app.Err(); err != nil {
+		return err
+	}
+	if app.debug {
+		app.PrintRoutes(os.Stdout)
+	}
+	return http.ListenAndServe(addr, app)
+}
+
+func (app *App) pathRoutes(path string) *pathRoutes {
+	shape := patternShape(path)
+	routes, ok := app.paths[shape]
+	if !ok {
+		routes = &pathRoutes{pattern: path}
+		app.paths[shape] = routes
+	}
+	return routes
+}
+
+func (app *App) AutoOptions() {
+	app.autoOptions = true
+	for _, routes := range app.paths {
+		app.registerAutoOptions(routes.pattern, routes)
+	}
+}
+
+func (app *App) registerAutoOptions(path string, routes *pathRoutes) {
+	if routes.autoOptions || slices.Contains(routes.methods, http.MethodOptions) {
+		return
+	}
+	routes.autoOptions = true
+
+	defaultHandler := app.applyMiddlewares(func(z *Z) {
+		z.SetHeader("Allow", routes.allow())
+		z.rw.WriteHeader(http.StatusNoContent)
+	})
+
+	app.register(&Route{Method: http.MethodOptions, Pattern: path}, func(z *Z) {
+		if routes.optionsHandler != nil {
+			routes.optionsHandler(z)
+			return
+		}
+		defaultHandler(z)
+	})
+}
+
+func (routes *pathRoutes) allow() string {
+	methods := slices.Clone(routes.methods)
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	return strings.Join(methods, ", ")
+}
+
+func (app *App) GET(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return app.handle(&Route{Method: http.MethodGet, Pattern: path}, handler, middlewares...)
+}
+
+func (app *App) PUT(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return app.handle(&Route{Method: http.MethodPut, Pattern: path}, handler, middlewares...)
+}
+
+func (app *App) POST(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return app.handle(&Route{Method: http.MethodPost, Pattern: path}, handler, middlewares...)
+}
+
+func (app *App) PATCH(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return app.handle(&Route{Method: http.MethodPatch, Pattern: path}, handler, middlewares...)
+}
+
+func (app *App) DELETE(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return app.handle(&Route{Method: http.MethodDelete, Pattern: path}, handler, middlewares...)
+}
+
+func (app *App) OPTIONS(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return app.handle(&Route{Method: http.MethodOptions, Pattern: path}, handler, middlewares...)
+}
+
+type Group struct {
+	app         *App
+	host        string
+	prefix      string
+	middlewares []MiddlewareFunc
+	version     *apiVersion
+}
+
+func (app *App) Group(prefix string, middlewares ...MiddlewareFunc) *Group {
+	return &Group{app: app, prefix: prefix, middlewares: middlewares}
+}
+
+func (app *App) Host(host string, middlewares ...MiddlewareFunc) *Group {
+	return &Group{app: app, host: strings.ToLower(host), middlewares: middlewares}
+}
+
+func (g *Group) Group(prefix string, middlewares ...MiddlewareFunc) *Group {
+	return &Group{
+		app:         g.app,
+		host:        g.host,
+		prefix:      g.prefix + prefix,
+		middlewares: append(slices.Clone(g.middlewares), middlewares...),
+		version:     g.version,
+	}
+}
+
+func (g *Group) Use(middlewareFunc MiddlewareFunc) {
+	g.middlewares = append(g.middlewares, middlewareFunc)
+}
+
+func (g *Group) handle(method string, path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	routeMiddlewares := append(slices.Clone(g.middlewares), middlewares...)
+	if g.version != nil {
+		return g.version.handle(g, method, path, handler, routeMiddlewares)
+	}
+	return g.app.handle(&Route{Method: method, Host: g.host, Pattern: g.prefix + path}, handler, routeMiddlewares...)
+}
+
+func (g *Group) GET(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.handle(http.MethodGet, path, handler, middlewares...)
 }
 
-func (app *App) GET(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
-	app.handle(http.MethodGet, path, handler, middlewares...)
+func (g *Group) PUT(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.handle(http.MethodPut, path, handler, middlewares...)
 }
 
-func (app *App) PUT(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
-	app.handle(http.MethodPut, path, handler, middlewares...)
+func (g *Group) POST(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.handle(http.MethodPost, path, handler, middlewares...)
 }
 
-func (app *App) POST(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
-	app.handle(http.MethodPost, path, handler, middlewares...)
+func (g *Group) PATCH(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.handle(http.MethodPatch, path, handler, middlewares...)
 }
 
-func (app *App) PATCH(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
-	app.handle(http.MethodPatch, path, handler, middlewares...)
+func (g *Group) DELETE(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.handle(http.MethodDelete, path, handler, middlewares...)
 }
 
-func (app *App) DELETE(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
-	app.handle(http.MethodDelete, path, handler, middlewares...)
+func (g *Group) OPTIONS(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.handle(http.MethodOptions, path, handler, middlewares...)
 }
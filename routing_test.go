@@ -1,7 +1,10 @@
 package z
 
 import (
+	"errors"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -80,6 +83,182 @@ func TestRouteAndAppMiddlewares(t *testing.T) {
 	}
 }
 
+func TestAutoOptions(t *testing.T) {
+	app := New()
+	app.GET("/before", func(z *Z) {})
+	app.AutoOptions()
+	app.GET("/users", func(z *Z) {})
+	app.POST("/users", func(z *Z) {})
+
+	for path, allow := range map[string]string{"/before": "GET, HEAD, OPTIONS", "/users": "GET, POST, HEAD, OPTIONS"} {
+		req := httptest.NewRequest("OPTIONS", path, nil)
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusNoContent, rr.Code)
+		}
+		if got := rr.Header().Get("Allow"); got != allow {
+			t.Errorf("%s: expected Allow %q, got %q", path, allow, got)
+		}
+	}
+}
+
+func TestAutoOptions_ExplicitHandlerWins(t *testing.T) {
+	app := New()
+	app.AutoOptions()
+	app.GET("/users", func(z *Z) {})
+	app.OPTIONS("/users", func(z *Z) { z.String(http.StatusOK, "custom") })
+	app.OPTIONS("/explicit", func(z *Z) { z.String(http.StatusOK, "explicit") })
+	app.GET("/explicit", func(z *Z) {})
+
+	for path, body := range map[string]string{"/users": "custom", "/explicit": "explicit"} {
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, httptest.NewRequest("OPTIONS", path, nil))
+		if rr.Body.String() != body {
+			t.Errorf("%s: expected explicit OPTIONS handler, got %q", path, rr.Body.String())
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	app := New()
+	var order []string
+	mw := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(z *Z) {
+				order = append(order, name)
+				next(z)
+			}
+		}
+	}
+
+	api := app.Group("/api", mw("api"))
+	v1 := api.Group("/v1", mw("v1"))
+	v1.Use(mw("v1-use"))
+	v1.GET("/users/{id}", func(z *Z) { order = append(order, "handler:"+z.PathValue("id")) }, mw("route"))
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/users/7", nil))
+
+	expected := []string{"api", "v1", "v1-use", "route", "handler:7"}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, order)
+	}
+	for i, v := range expected {
+		if order[i] != v {
+			t.Errorf("Expected %s at position %d, got %s", v, i, order[i])
+		}
+	}
+
+	order = nil
+	api.POST("/ping", func(z *Z) { order = append(order, "ping") })
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/ping", nil))
+	if len(order) != 2 || order[0] != "api" {
+		t.Errorf("Nested group middlewares should not leak into the parent, got %v", order)
+	}
+}
+
+func TestRouteMetadata(t *testing.T) {
+	app := New()
+
+	var seen []string
+	app.Use(func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			seen = append(seen, z.RoutePattern(), z.RouteName())
+			if z.Route().HasTag("admin") && z.RouteMeta("audit") == true {
+				seen = append(seen, "audited")
+			}
+			next(z)
+		}
+	})
+
+	app.Group("/admin").DELETE("/users/{id}", func(z *Z) {}).
+		WithName("users.delete").
+		WithTags("admin").
+		WithMeta("audit", true)
+	app.GET("/health", func(z *Z) {})
+
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/admin/users/1", nil))
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	expected := []string{"/admin/users/{id}", "users.delete", "audited", "/health", ""}
+	if strings.Join(seen, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v, got %v", expected, seen)
+	}
+
+	if (&Z{}).RoutePattern() != "" || (&Z{}).RouteMeta("x") != nil {
+		t.Fatal("Expected empty route info without a route")
+	}
+}
+
+func listUsers(z *Z) {}
+
+func TestRoutes(t *testing.T) {
+	app := New()
+	app.Use(Middlewares.RequestID())
+	app.GET("/users", listUsers).WithName("users.list")
+	app.Group("/admin", Middlewares.Recovery()).POST("/users/{id}", func(z *Z) {}, Middlewares.Logging())
+
+	routes := app.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %d", len(routes))
+	}
+	if r := routes[0]; r.Method != "GET" || r.Pattern != "/users" || r.Name != "users.list" ||
+		r.MiddlewareCount != 1 || r.HandlerName != "github.com/wxlai90/z.listUsers" {
+		t.Fatalf("Unexpected route: %+v", r)
+	}
+	if r := routes[1]; r.Method != "POST" || r.Pattern != "/admin/users/{id}" || r.MiddlewareCount != 3 {
+		t.Fatalf("Unexpected route: %+v", r)
+	}
+
+	var b strings.Builder
+	app.PrintRoutes(&b)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "METHOD") || !strings.Contains(lines[1], "users.list") {
+		t.Fatalf("Unexpected route table:\n%s", b.String())
+	}
+}
+
+func TestRouteConflicts(t *testing.T) {
+	app := New()
+	app.GET("/users/{id}", func(z *Z) {})
+	app.GET("/users/{name}", func(z *Z) {})
+	app.GET("/users/{id}", func(z *Z) {})
+	app.GET("/bad/{", func(z *Z) {})
+
+	err := app.Err()
+	if !errors.Is(err, ErrRouteConflict) || !errors.Is(err, ErrInvalidRoute) {
+		t.Fatalf("Expected conflict and invalid route errors, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "GET /users/{name}") {
+		t.Fatalf("Expected error to name the route, got %v", err)
+	}
+	if len(app.Routes()) != 1 {
+		t.Fatalf("Expected failed registrations to be left out, got %v", app.Routes())
+	}
+	if err := app.Listen("127.0.0.1:0"); !errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("Expected Listen to refuse to start, got %v", err)
+	}
+}
+
+func TestRouteConflicts_OverlappingShapes(t *testing.T) {
+	app := New()
+	app.GET("/a/{x}", func(z *Z) {})
+	app.GET("/{y}/b", func(z *Z) {})
+
+	err := app.Err()
+	if !errors.Is(err, ErrRouteConflict) || errors.Is(err, ErrInvalidRoute) {
+		t.Fatalf("Expected only a conflict error, got %v", err)
+	}
+
+	app = New()
+	app.GET("/{a}/{a}", func(z *Z) {})
+	if err := app.Err(); !errors.Is(err, ErrInvalidRoute) || errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("Expected only an invalid route error, got %v", err)
+	}
+}
+
 type mockResponseWriter struct{}
 
 func (m *mockResponseWriter) Header() http.Header       { return http.Header{} }
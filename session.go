@@ -0,0 +1,338 @@
+package z
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const flashesSessionKey = "_flashes"
+
+type SessionStore interface {
+	Load(ctx context.Context, cookieValue string) (id string, values map[string]any, err error)
+	Save(ctx context.Context, id string, values map[string]any, ttl time.Duration) (cookieValue string, err error)
+	Delete(ctx context.Context, id string) error
+}
+
+type Session struct {
+	mu        sync.Mutex
+	id        string
+	previous  string
+	values    map[string]any
+	modified  bool
+	destroyed bool
+}
+
+func newSession(id string, values map[string]any) *Session {
+	if values == nil {
+		values = map[string]any{}
+	}
+	return &Session{id: id, values: values}
+}
+
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+func (s *Session) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+func (s *Session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.modified = true
+}
+
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+func (s *Session) AddFlash(value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes, _ := s.values[flashesSessionKey].([]any)
+	s.values[flashesSessionKey] = append(flashes, value)
+	s.modified = true
+}
+
+func (s *Session) Flashes() []any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes, ok := s.values[flashesSessionKey].([]any)
+	if !ok {
+		return nil
+	}
+	delete(s.values, flashesSessionKey)
+	s.modified = true
+	return flashes
+}
+
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.previous == "" {
+		s.previous = s.id
+	}
+	s.id = generateSessionID()
+	s.modified = true
+}
+
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]any{}
+	s.destroyed = true
+	s.modified = true
+}
+
+func generateSessionID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (z *Z) Session() *Session {
+	session, _ := z.r.Context().Value(sessionContextKey).(*Session)
+	return session
+}
+
+type SessionConfig struct {
+	Store          SessionStore
+	CookieName     string
+	CookiePath     string
+	CookieDomain   string
+	MaxAge         time.Duration
+	SameSite       http.SameSite
+	InsecureCookie bool
+}
+
+func (middlewaresRegistry) Session(store SessionStore) MiddlewareFunc {
+	return Middlewares.SessionWithCfg(SessionConfig{Store: store})
+}
+
+func (middlewaresRegistry) SessionWithCfg(cfg SessionConfig) MiddlewareFunc {
+	if cfg.CookieName == "" {
+		cfg.CookieName = "session"
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = 24 * time.Hour
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			var session *Session
+			if cookie, err := z.r.Cookie(cfg.CookieName); err == nil {
+				id, values, err := cfg.Store.Load(z.r.Context(), cookie.Value)
+				if err != nil {
+					slog.Error("Failed to load session", "err", err)
+				} else if id != "" {
+					session = newSession(id, values)
+				}
+			}
+			if session == nil {
+				session = newSession(generateSessionID(), nil)
+			}
+			z.setContextValue(sessionContextKey, session)
+
+			writer := &responseWriter{ResponseWriter: z.rw}
+			writer.beforeHeaders = func() {
+				if err := saveSession(z, cfg, session); err != nil {
+					slog.Error("Failed to save session", "err", err)
+				}
+			}
+			z.rw = writer
+
+			next(z)
+
+			if !writer.wroteHeader {
+				writer.beforeHeaders = nil
+				if err := saveSession(z, cfg, session); err != nil {
+					slog.Error("Failed to save session", "err", err)
+				}
+			}
+		}
+	}
+}
+
+func saveSession(z *Z, cfg SessionConfig, session *Session) error {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if !session.modified {
+		return nil
+	}
+	session.modified = false
+
+	ctx := z.r.Context()
+	if session.previous != "" {
+		if err := cfg.Store.Delete(ctx, session.previous); err != nil {
+			return fmt.Errorf("failed to delete previous session: %w", err)
+		}
+		session.previous = ""
+	}
+
+	cookie := &http.Cookie{
+		Name:     cfg.CookieName,
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		HttpOnly: true,
+		Secure:   !cfg.InsecureCookie,
+		SameSite: cfg.SameSite,
+	}
+
+	if session.destroyed {
+		if err := cfg.Store.Delete(ctx, session.id); err != nil {
+			return fmt.Errorf("failed to delete session: %w", err)
+		}
+		cookie.MaxAge = -1
+		http.SetCookie(z.rw, cookie)
+		return nil
+	}
+
+	value, err := cfg.Store.Save(ctx, session.id, session.values, cfg.MaxAge)
+	if err != nil {
+		return err
+	}
+	cookie.Value = value
+	cookie.MaxAge = int(cfg.MaxAge.Seconds())
+	http.SetCookie(z.rw, cookie)
+	return nil
+}
+
+type CookieSessionStore struct {
+	cookie *SecureCookie
+	name   string
+}
+
+func NewCookieSessionStore(sc *SecureCookie) *CookieSessionStore {
+	return &CookieSessionStore{cookie: sc, name: "session"}
+}
+
+type cookieSessionPayload struct {
+	ID      string         `json:"id"`
+	Values  map[string]any `json:"values"`
+	Expires int64          `json:"exp"`
+}
+
+func (s *CookieSessionStore) Load(ctx context.Context, cookieValue string) (string, map[string]any, error) {
+	plaintext, err := s.cookie.Decrypt(s.name, cookieValue)
+	if err != nil {
+		return "", nil, nil
+	}
+	var payload cookieSessionPayload
+	if err := json.Unmarshal([]byte(plaintext), &payload); err != nil {
+		return "", nil, nil
+	}
+	if time.Now().Unix() > payload.Expires {
+		return "", nil, nil
+	}
+	return payload.ID, payload.Values, nil
+}
+
+func (s *CookieSessionStore) Save(ctx context.Context, id string, values map[string]any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(cookieSessionPayload{ID: id, Values: values, Expires: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
+	value, err := s.cookie.Encrypt(s.name, string(data))
+	if err != nil {
+		return "", err
+	}
+	if len(value) > 4000 {
+		return "", fmt.Errorf("session cookie exceeds 4KB, use a server-side store")
+	}
+	return value, nil
+}
+
+func (s *CookieSessionStore) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+type memorySessionEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+const memorySessionSweepInterval = time.Minute
+
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySessionEntry
+	now       func() time.Time
+	nextSweep time.Time
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]memorySessionEntry{}, now: time.Now}
+}
+
+func (s *MemorySessionStore) Load(ctx context.Context, cookieValue string) (string, map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.sessions[cookieValue]
+	if !ok {
+		return "", nil, nil
+	}
+	if s.now().After(entry.expires) {
+		delete(s.sessions, cookieValue)
+		return "", nil, nil
+	}
+	var values map[string]any
+	if err := json.Unmarshal(entry.data, &values); err != nil {
+		return "", nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return cookieValue, values, nil
+}
+
+func (s *MemorySessionStore) Save(ctx context.Context, id string, values map[string]any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.After(s.nextSweep) {
+		for k, entry := range s.sessions {
+			if now.After(entry.expires) {
+				delete(s.sessions, k)
+			}
+		}
+		s.nextSweep = now.Add(memorySessionSweepInterval)
+	}
+	s.sessions[id] = memorySessionEntry{data: data, expires: now.Add(ttl)}
+	return id, nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
@@ -0,0 +1,170 @@
+package z
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func sessionCookie(t *testing.T, rr *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "session" {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSessionMiddleware_MemoryStore(t *testing.T) {
+	store := NewMemorySessionStore()
+	mw := Middlewares.Session(store)
+
+	set := mw(func(z *Z) {
+		z.Session().Set("user", "alice")
+		z.String(http.StatusOK, "ok")
+	})
+	rr := httptest.NewRecorder()
+	set(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+
+	cookie := sessionCookie(t, rr)
+	if cookie == nil {
+		t.Fatal("Expected session cookie to be set before the body was written")
+	}
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Fatalf("Unexpected cookie attributes: %+v", cookie)
+	}
+
+	var user any
+	get := mw(func(z *Z) { user = z.Session().Get("user") })
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	get(&Z{rw: rr, r: req})
+
+	if user != "alice" {
+		t.Fatalf("Expected alice, got %v", user)
+	}
+	if sessionCookie(t, rr) != nil {
+		t.Fatal("Expected unmodified session not to be saved")
+	}
+}
+
+func TestSessionMiddleware_CookieStore(t *testing.T) {
+	sc, _ := NewSecureCookie(testCookieKey)
+	mw := Middlewares.SessionWithCfg(SessionConfig{
+		Store:          NewCookieSessionStore(sc),
+		MaxAge:         time.Hour,
+		InsecureCookie: true,
+	})
+
+	rr := httptest.NewRecorder()
+	mw(func(z *Z) { z.Session().Set("count", 1) })(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+
+	cookie := sessionCookie(t, rr)
+	if cookie == nil || cookie.Secure || cookie.MaxAge != 3600 {
+		t.Fatalf("Unexpected cookie: %+v", cookie)
+	}
+
+	var count any
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	mw(func(z *Z) { count = z.Session().Get("count") })(&Z{rw: httptest.NewRecorder(), r: req})
+	if count != float64(1) {
+		t.Fatalf("Expected count 1, got %v", count)
+	}
+
+	var id string
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "garbage"})
+	mw(func(z *Z) { id = z.Session().ID() })(&Z{rw: httptest.NewRecorder(), r: req})
+	if id == "" {
+		t.Fatal("Expected a fresh session for an invalid cookie")
+	}
+}
+
+func TestSession_Flashes(t *testing.T) {
+	s := newSession("id", nil)
+	s.AddFlash("saved")
+	s.AddFlash("again")
+
+	if flashes := s.Flashes(); len(flashes) != 2 || flashes[0] != "saved" {
+		t.Fatalf("Unexpected flashes: %v", flashes)
+	}
+	if flashes := s.Flashes(); flashes != nil {
+		t.Fatalf("Expected flashes to be consumed, got %v", flashes)
+	}
+}
+
+func TestSession_RegenerateAndDestroy(t *testing.T) {
+	store := NewMemorySessionStore()
+	mw := Middlewares.Session(store)
+
+	rr := httptest.NewRecorder()
+	mw(func(z *Z) { z.Session().Set("user", "alice") })(&Z{rw: rr, r: httptest.NewRequest("GET", "/", nil)})
+	first := sessionCookie(t, rr)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(first)
+	rr = httptest.NewRecorder()
+	mw(func(z *Z) { z.Session().Regenerate() })(&Z{rw: rr, r: req})
+	second := sessionCookie(t, rr)
+
+	if second == nil || second.Value == first.Value {
+		t.Fatal("Expected a new session ID after Regenerate")
+	}
+	if id, _, _ := store.Load(context.Background(), first.Value); id != "" {
+		t.Fatal("Expected old session to be deleted")
+	}
+	if _, values, _ := store.Load(context.Background(), second.Value); values["user"] != "alice" {
+		t.Fatalf("Expected values to carry over, got %v", values)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(second)
+	rr = httptest.NewRecorder()
+	mw(func(z *Z) { z.Session().Destroy() })(&Z{rw: rr, r: req})
+
+	if cookie := sessionCookie(t, rr); cookie == nil || cookie.MaxAge != -1 {
+		t.Fatalf("Expected session cookie to be cleared, got %+v", cookie)
+	}
+	if id, _, _ := store.Load(context.Background(), second.Value); id != "" {
+		t.Fatal("Expected destroyed session to be deleted")
+	}
+}
+
+func TestMemorySessionStore_Expiry(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemorySessionStore()
+	store.now = clock.Now
+
+	store.Save(context.Background(), "abc", map[string]any{"a": 1}, time.Minute)
+	if id, _, _ := store.Load(context.Background(), "abc"); id != "abc" {
+		t.Fatal("Expected session to load")
+	}
+	clock.Advance(2 * time.Minute)
+	if id, _, _ := store.Load(context.Background(), "abc"); id != "" {
+		t.Fatal("Expected session to expire")
+	}
+}
+
+func TestMemorySessionStore_SweepsPeriodically(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemorySessionStore()
+	store.now = clock.Now
+
+	store.Save(context.Background(), "old", nil, time.Second)
+	clock.Advance(2 * time.Second)
+	store.Save(context.Background(), "new", nil, time.Hour)
+	if len(store.sessions) != 2 {
+		t.Fatalf("Expected no sweep before the interval, got %d sessions", len(store.sessions))
+	}
+
+	clock.Advance(memorySessionSweepInterval)
+	store.Save(context.Background(), "newer", nil, time.Hour)
+	if _, ok := store.sessions["old"]; ok || len(store.sessions) != 2 {
+		t.Fatalf("Expected expired sessions to be swept, got %d sessions", len(store.sessions))
+	}
+}
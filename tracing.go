@@ -0,0 +1,606 @@
+package z
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+	tracerScopeName   = "github.com/wxlai90/z"
+)
+
+type TraceID [16]byte
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+type SpanID [8]byte
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+const TraceFlagsSampled byte = 0x01
+
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags byte
+	TraceState string
+	Remote     bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&TraceFlagsSampled != 0
+}
+
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceFlags)
+}
+
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return sc, fmt.Errorf("invalid traceparent version %q", parts[0])
+	}
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if !isLowerHex(parts[1]) || !isLowerHex(parts[2]) || !isLowerHex(parts[3]) {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+
+	hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	flags, _ := hex.DecodeString(parts[3])
+	sc.TraceFlags = flags[0]
+	sc.Remote = true
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: all-zero ID", value)
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type SpanStatusCode int
+
+const (
+	SpanStatusUnset SpanStatusCode = 0
+	SpanStatusOK    SpanStatusCode = 1
+	SpanStatusError SpanStatusCode = 2
+)
+
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]any
+}
+
+type Span struct {
+	mu            sync.Mutex
+	name          string
+	kind          SpanKind
+	spanContext   SpanContext
+	parentSpanID  SpanID
+	startTime     time.Time
+	endTime       time.Time
+	attributes    map[string]any
+	events        []SpanEvent
+	statusCode    SpanStatusCode
+	statusMessage string
+	ended         bool
+	exporter      SpanExporter
+}
+
+func (s *Span) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) Kind() SpanKind {
+	return s.kind
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return s.spanContext
+}
+
+func (s *Span) ParentSpanID() SpanID {
+	return s.parentSpanID
+}
+
+func (s *Span) StartTime() time.Time {
+	return s.startTime
+}
+
+func (s *Span) EndTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.endTime
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *Span) Attributes() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]any, len(s.attributes))
+	for k, v := range s.attributes {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *Span) AddEvent(name string, attributes map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, SpanEvent{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+func (s *Span) Events() []SpanEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SpanEvent(nil), s.events...)
+}
+
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.AddEvent("exception", map[string]any{
+		"exception.type":    fmt.Sprintf("%T", err),
+		"exception.message": err.Error(),
+	})
+	s.SetStatus(SpanStatusError, err.Error())
+}
+
+func (s *Span) SetStatus(code SpanStatusCode, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statusCode == SpanStatusOK {
+		return
+	}
+	s.statusCode = code
+	if code == SpanStatusError {
+		s.statusMessage = message
+	}
+}
+
+func (s *Span) Status() (SpanStatusCode, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statusCode, s.statusMessage
+}
+
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.endTime = time.Now()
+	s.mu.Unlock()
+
+	if s.exporter != nil && s.spanContext.IsSampled() {
+		if err := s.exporter.ExportSpans(context.Background(), []*Span{s}); err != nil {
+			slog.Error("Failed to export span", "err", err)
+		}
+	}
+}
+
+func newSpan(name string, kind SpanKind, parent SpanContext, exporter SpanExporter) *Span {
+	sc := SpanContext{
+		TraceID:    parent.TraceID,
+		TraceFlags: parent.TraceFlags,
+		TraceState: parent.TraceState,
+	}
+	if !parent.IsValid() {
+		rand.Read(sc.TraceID[:])
+		sc.TraceFlags = TraceFlagsSampled
+	}
+	rand.Read(sc.SpanID[:])
+
+	return &Span{
+		name:         name,
+		kind:         kind,
+		spanContext:  sc,
+		parentSpanID: parent.SpanID,
+		startTime:    time.Now(),
+		attributes:   map[string]any{},
+		exporter:     exporter,
+	}
+}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey).(*Span)
+	return span
+}
+
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	var parent SpanContext
+	var exporter SpanExporter
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.spanContext
+		exporter = p.exporter
+	}
+	span := newSpan(name, SpanKindInternal, parent, exporter)
+	return ContextWithSpan(ctx, span), span
+}
+
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	header.Set(traceparentHeader, span.spanContext.Traceparent())
+	if span.spanContext.TraceState != "" {
+		header.Set(tracestateHeader, span.spanContext.TraceState)
+	}
+}
+
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+}
+
+type TracingConfig struct {
+	Exporter SpanExporter
+	Sampler  func(z *Z) bool
+}
+
+func (middlewaresRegistry) Tracing() MiddlewareFunc {
+	return Middlewares.TracingWithCfg(TracingConfig{})
+}
+
+func (middlewaresRegistry) TracingWithCfg(cfg TracingConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(z *Z) {
+			parent, err := ParseTraceparent(z.r.Header.Get(traceparentHeader))
+			if err == nil {
+				parent.TraceState = z.r.Header.Get(tracestateHeader)
+			}
+
+			span := newSpan(spanName(z), SpanKindServer, parent, cfg.Exporter)
+			if !parent.IsValid() && cfg.Sampler != nil && !cfg.Sampler(z) {
+				span.spanContext.TraceFlags &^= TraceFlagsSampled
+			}
+
+			span.SetAttribute("http.request.method", z.r.Method)
+			span.SetAttribute("url.path", z.r.URL.Path)
+			if pattern := z.RoutePattern(); pattern != "" {
+				span.SetAttribute("http.route", pattern)
+			}
+			if z.r.Host != "" {
+				span.SetAttribute("server.address", z.r.Host)
+			}
+			if ua := z.r.UserAgent(); ua != "" {
+				span.SetAttribute("user_agent.original", ua)
+			}
+
+			z.setContextValue(spanContextKey, span)
+			writer := &responseWriter{ResponseWriter: z.rw}
+			z.rw = writer
+
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					span.RecordError(fmt.Errorf("panic: %v", recovered))
+					span.End()
+					panic(recovered)
+				}
+
+				status := writer.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				span.SetAttribute("http.response.status_code", status)
+				if status >= http.StatusInternalServerError {
+					span.SetStatus(SpanStatusError, http.StatusText(status))
+				}
+				span.End()
+			}()
+
+			next(z)
+		}
+	}
+}
+
+func spanName(z *Z) string {
+	pattern := z.RoutePattern()
+	if pattern == "" {
+		return z.r.Method
+	}
+	return z.r.Method + " " + pattern
+}
+
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+type OTLPHTTPExporterConfig struct {
+	Endpoint      string
+	Headers       map[string]string
+	ServiceName   string
+	Client        *http.Client
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+type OTLPHTTPExporter struct {
+	cfg     OTLPHTTPExporterConfig
+	mu      sync.Mutex
+	pending []*Span
+	kick    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewOTLPHTTPExporter(cfg OTLPHTTPExporterConfig) *OTLPHTTPExporter {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://localhost:4318/v1/traces"
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "unknown_service"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+
+	e := &OTLPHTTPExporter{
+		cfg:  cfg,
+		kick: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+func (e *OTLPHTTPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	e.mu.Lock()
+	e.pending = append(e.pending, spans...)
+	full := len(e.pending) >= e.cfg.BatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (e *OTLPHTTPExporter) loop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.kick:
+		}
+		if err := e.Flush(context.Background()); err != nil {
+			slog.Error("Failed to export spans", "err", err)
+		}
+	}
+}
+
+func (e *OTLPHTTPExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to export spans: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *OTLPHTTPExporter) Shutdown(ctx context.Context) error {
+	select {
+	case <-e.done:
+	default:
+		close(e.done)
+	}
+	e.wg.Wait()
+	return e.Flush(ctx)
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]any
+		switch val := v.(type) {
+		case string:
+			value = map[string]any{"stringValue": val}
+		case bool:
+			value = map[string]any{"boolValue": val}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(val)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(val, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": val}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(val)}
+		}
+		out = append(out, otlpKeyValue{Key: k, Value: value})
+	}
+	return out
+}
+
+func (e *OTLPHTTPExporter) payload(spans []*Span) map[string]any {
+	otlpSpans := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := map[string]any{
+			"traceId":           s.spanContext.TraceID.String(),
+			"spanId":            s.spanContext.SpanID.String(),
+			"name":              s.name,
+			"kind":              int(s.kind),
+			"startTimeUnixNano": strconv.FormatInt(s.startTime.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.endTime.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+			"status":            map[string]any{"code": int(s.statusCode), "message": s.statusMessage},
+		}
+		if s.parentSpanID.IsValid() {
+			span["parentSpanId"] = s.parentSpanID.String()
+		}
+		if s.spanContext.TraceState != "" {
+			span["traceState"] = s.spanContext.TraceState
+		}
+		if len(s.events) > 0 {
+			events := make([]map[string]any, 0, len(s.events))
+			for _, ev := range s.events {
+				events = append(events, map[string]any{
+					"name":         ev.Name,
+					"timeUnixNano": strconv.FormatInt(ev.Time.UnixNano(), 10),
+					"attributes":   otlpAttributes(ev.Attributes),
+				})
+			}
+			span["events"] = events
+		}
+		s.mu.Unlock()
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	return map[string]any{
+		"resourceSpans": []map[string]any{{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": e.cfg.ServiceName}),
+			},
+			"scopeSpans": []map[string]any{{
+				"scope": map[string]any{"name": tracerScopeName},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+}
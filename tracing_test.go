@@ -0,0 +1,260 @@
+package z
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("ParseTraceparent failed: %v", err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected trace ID %s", sc.TraceID)
+	}
+	if sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected span ID %s", sc.SpanID)
+	}
+	if !sc.IsSampled() || !sc.Remote {
+		t.Errorf("Expected a sampled remote span context, got %+v", sc)
+	}
+	if sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Traceparent round trip failed: %s", sc.Traceparent())
+	}
+}
+
+func TestParseTraceparent_Invalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, v := range invalid {
+		if _, err := ParseTraceparent(v); err == nil {
+			t.Errorf("Expected error for %q", v)
+		}
+	}
+
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future"); err != nil {
+		t.Errorf("Future versions may carry extra fields, got %v", err)
+	}
+}
+
+func TestTracingMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	app := New()
+	app.Use(Middlewares.TracingWithCfg(TracingConfig{Exporter: exporter}))
+
+	var fromContext *Span
+	app.GET("/users/{id}", func(z *Z) {
+		fromContext = SpanFromContext(z.Context())
+		z.Ok("ok")
+	})
+
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "vendor=abc")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 exported span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span != fromContext {
+		t.Error("Span should be available from z.Context()")
+	}
+	if span.Name() != "GET /users/{id}" {
+		t.Errorf("Expected span named after the route pattern, got %q", span.Name())
+	}
+	if span.Kind() != SpanKindServer {
+		t.Errorf("Expected a server span, got %v", span.Kind())
+	}
+	sc := span.SpanContext()
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected trace ID to be continued, got %s", sc.TraceID)
+	}
+	if span.ParentSpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected remote parent span ID, got %s", span.ParentSpanID())
+	}
+	if sc.TraceState != "vendor=abc" {
+		t.Errorf("Expected tracestate to be propagated, got %q", sc.TraceState)
+	}
+	attrs := span.Attributes()
+	if attrs["http.route"] != "/users/{id}" || attrs["http.response.status_code"] != 200 {
+		t.Errorf("Unexpected span attributes: %v", attrs)
+	}
+}
+
+func TestTracingMiddleware_NewTraceAndErrorStatus(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	mw := Middlewares.TracingWithCfg(TracingConfig{Exporter: exporter})
+
+	req := httptest.NewRequest("GET", "/fail", nil)
+	z := &Z{rw: httptest.NewRecorder(), r: req}
+	mw(func(z *Z) { z.String(http.StatusBadGateway, "upstream down") })(z)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 exported span, got %d", len(spans))
+	}
+	if !spans[0].SpanContext().IsValid() || spans[0].ParentSpanID().IsValid() {
+		t.Errorf("Expected a new root span, got %+v", spans[0].SpanContext())
+	}
+	if spans[0].Name() != "GET" {
+		t.Errorf("Expected span named after the method without a route, got %q", spans[0].Name())
+	}
+	if code, _ := spans[0].Status(); code != SpanStatusError {
+		t.Errorf("Expected error status for 502, got %v", code)
+	}
+}
+
+func TestTracingMiddleware_RecordsPanics(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	mw := Middlewares.TracingWithCfg(TracingConfig{Exporter: exporter})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	z := &Z{rw: httptest.NewRecorder(), r: req}
+
+	func() {
+		defer func() { recover() }()
+		mw(func(z *Z) { panic("boom") })(z)
+	}()
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 exported span, got %d", len(spans))
+	}
+	if code, msg := spans[0].Status(); code != SpanStatusError || !strings.Contains(msg, "boom") {
+		t.Errorf("Expected panic to be recorded, got %v %q", code, msg)
+	}
+	if events := spans[0].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("Expected an exception event, got %v", events)
+	}
+}
+
+func TestTracingMiddleware_UnsampledParentIsNotExported(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	mw := Middlewares.TracingWithCfg(TracingConfig{Exporter: exporter})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	z := &Z{rw: httptest.NewRecorder(), r: req}
+	mw(func(z *Z) {})(z)
+
+	if len(exporter.Spans()) != 0 {
+		t.Error("Spans of unsampled traces should not be exported")
+	}
+}
+
+func TestTracingMiddleware_Sampler(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	mw := Middlewares.TracingWithCfg(TracingConfig{
+		Exporter: exporter,
+		Sampler:  func(z *Z) bool { return z.r.URL.Path != "/healthz" },
+	})
+
+	for _, path := range []string{"/healthz", "/orders"} {
+		z := &Z{rw: httptest.NewRecorder(), r: httptest.NewRequest("GET", path, nil)}
+		mw(func(z *Z) {})(z)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].Attributes()["url.path"] != "/orders" {
+		t.Errorf("Expected only /orders to be sampled, got %d spans", len(spans))
+	}
+}
+
+func TestStartSpanAndInjectTraceContext(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	mw := Middlewares.TracingWithCfg(TracingConfig{Exporter: exporter})
+
+	var outgoing http.Header
+	z := &Z{rw: httptest.NewRecorder(), r: httptest.NewRequest("GET", "/", nil)}
+	mw(func(z *Z) {
+		ctx, child := StartSpan(z.Context(), "db.query")
+		child.RecordError(errors.New("timeout"))
+		outgoing = http.Header{}
+		InjectTraceContext(ctx, outgoing)
+		child.End()
+	})(z)
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected child and server spans, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if child.ParentSpanID() != server.SpanContext().SpanID {
+		t.Error("Child span should be parented to the server span")
+	}
+	if child.SpanContext().TraceID != server.SpanContext().TraceID {
+		t.Error("Child span should share the trace ID")
+	}
+	if outgoing.Get("traceparent") != child.SpanContext().Traceparent() {
+		t.Errorf("Expected traceparent to be injected, got %q", outgoing.Get("traceparent"))
+	}
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	received := make(chan map[string]any, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("Unexpected export headers: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]any
+		json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPHTTPExporter(OTLPHTTPExporterConfig{
+		Endpoint:      collector.URL + "/v1/traces",
+		Headers:       map[string]string{"X-Api-Key": "secret"},
+		ServiceName:   "orders",
+		FlushInterval: time.Hour,
+	})
+
+	span := newSpan("GET /orders", SpanKindServer, SpanContext{}, exporter)
+	span.SetAttribute("http.response.status_code", 200)
+	span.End()
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	payload := <-received
+	encoded, _ := json.Marshal(payload)
+	for _, want := range []string{`"service.name"`, `"stringValue":"orders"`, `"name":"GET /orders"`, `"kind":2`, `"intValue":"200"`, span.SpanContext().TraceID.String()} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("OTLP payload should contain %s, got %s", want, encoded)
+		}
+	}
+}
+
+func TestOTLPHTTPExporter_CollectorError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPHTTPExporter(OTLPHTTPExporterConfig{Endpoint: collector.URL, FlushInterval: time.Hour})
+	defer exporter.Shutdown(context.Background())
+
+	exporter.ExportSpans(context.Background(), []*Span{newSpan("x", SpanKindInternal, SpanContext{}, nil)})
+	if err := exporter.Flush(context.Background()); err == nil {
+		t.Error("Expected an error when the collector rejects spans")
+	}
+}
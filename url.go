@@ -0,0 +1,82 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrRouteNotFound     = errors.New("route not found")
+	ErrMissingRouteParam = errors.New("missing route param")
+	ErrInvalidRouteParam = errors.New("invalid route param")
+)
+
+func (app *App) URL(name string, params map[string]string, query url.Values) (string, error) {
+	var route *Route
+	for _, r := range app.routes {
+		if r.Name == name {
+			route = r
+			break
+		}
+	}
+	if route == nil {
+		return "", fmt.Errorf("%w: %s", ErrRouteNotFound, name)
+	}
+
+	path, err := buildRoutePath(route.Pattern, route.Constraints, params)
+	if err != nil {
+		return "", fmt.Errorf("route %s: %w", name, err)
+	}
+	if encoded := query.Encode(); encoded != "" {
+		path += "?" + encoded
+	}
+	return path, nil
+}
+
+func buildRoutePath(pattern string, constraints map[string]ParamConstraint, params map[string]string) (string, error) {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := segment[1 : len(segment)-1]
+		if name == "$" {
+			segments[i] = ""
+			continue
+		}
+
+		name, rest := strings.CutSuffix(name, "...")
+		value, ok := params[name]
+		if !ok || (value == "" && !rest) {
+			return "", fmt.Errorf("%w: %s", ErrMissingRouteParam, name)
+		}
+		if c, ok := constraints[name]; ok && !c.Match(value) {
+			return "", fmt.Errorf("%w: %s must match %s", ErrInvalidRouteParam, name, c)
+		}
+		if !rest {
+			segments[i] = url.PathEscape(value)
+			continue
+		}
+		parts := strings.Split(value, "/")
+		for j, part := range parts {
+			parts[j] = url.PathEscape(part)
+		}
+		segments[i] = strings.Join(parts, "/")
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+func (z *Z) URL(name string, params map[string]string, query url.Values) (string, error) {
+	return z.app.URL(name, params, query)
+}
+
+func (z *Z) RedirectToRoute(name string, params map[string]string, code int) error {
+	target, err := z.URL(name, params, nil)
+	if err != nil {
+		return err
+	}
+	z.Redirect(target, code)
+	return nil
+}
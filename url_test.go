@@ -0,0 +1,61 @@
+package z
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAppURL(t *testing.T) {
+	app := New()
+	app.GET("/users/{id}", func(z *Z) {}).WithName("user.show")
+	app.GET("/files/{path...}", func(z *Z) {}).WithName("files")
+	app.GET("/posts/{$}", func(z *Z) {}).WithName("posts")
+	app.Group("/orgs/{org}").GET("/repos/{repo}", func(z *Z) {}).WithName("repo")
+
+	cases := []struct {
+		name   string
+		params map[string]string
+		query  url.Values
+		want   string
+	}{
+		{"user.show", map[string]string{"id": "42"}, nil, "/users/42"},
+		{"user.show", map[string]string{"id": "a b/c?"}, nil, "/users/a%20b%2Fc%3F"},
+		{"user.show", map[string]string{"id": "42"}, url.Values{"tab": {"posts"}, "q": {"a&b"}}, "/users/42?q=a%26b&tab=posts"},
+		{"files", map[string]string{"path": "docs/my file.txt"}, nil, "/files/docs/my%20file.txt"},
+		{"files", map[string]string{"path": ""}, nil, "/files/"},
+		{"posts", nil, nil, "/posts/"},
+		{"repo", map[string]string{"org": "acme", "repo": "z"}, nil, "/orgs/acme/repos/z"},
+	}
+	for _, c := range cases {
+		got, err := app.URL(c.name, c.params, c.query)
+		if err != nil || got != c.want {
+			t.Errorf("URL(%s, %v) = %q, %v; want %q", c.name, c.params, got, err, c.want)
+		}
+	}
+
+	if _, err := app.URL("missing", nil, nil); !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("Expected ErrRouteNotFound, got %v", err)
+	}
+	if _, err := app.URL("repo", map[string]string{"org": "acme"}, nil); !errors.Is(err, ErrMissingRouteParam) {
+		t.Errorf("Expected ErrMissingRouteParam, got %v", err)
+	}
+}
+
+func TestRedirectToRoute(t *testing.T) {
+	app := New()
+	app.GET("/users/{id}", func(z *Z) {}).WithName("user.show")
+	app.POST("/users", func(z *Z) {
+		if err := z.RedirectToRoute("user.show", map[string]string{"id": "7"}, http.StatusSeeOther); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("POST", "/users", nil))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/users/7" {
+		t.Fatalf("Unexpected redirect: %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
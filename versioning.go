@@ -0,0 +1,182 @@
+package z
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type VersioningConfig struct {
+	Prefix    string
+	URLPrefix bool
+	Header    string
+	Vendor    string
+	Default   string
+}
+
+type Deprecation struct {
+	Date   time.Time
+	Sunset time.Time
+	Link   string
+}
+
+type Versioning struct {
+	app      *App
+	cfg      VersioningConfig
+	versions map[string]*apiVersion
+}
+
+type apiVersion struct {
+	versioning  *Versioning
+	name        string
+	deprecation *Deprecation
+}
+
+func (app *App) Versioning(cfg VersioningConfig) *Versioning {
+	if !cfg.URLPrefix && cfg.Header == "" && cfg.Vendor == "" {
+		cfg.URLPrefix = true
+	}
+	cfg.Default = normalizeVersion(cfg.Default)
+	return &Versioning{app: app, cfg: cfg, versions: map[string]*apiVersion{}}
+}
+
+func (v *Versioning) Version(name string, middlewares ...MiddlewareFunc) *Group {
+	name = normalizeVersion(name)
+	version, ok := v.versions[name]
+	if !ok {
+		version = &apiVersion{versioning: v, name: name}
+		v.versions[name] = version
+	}
+	if v.cfg.Default == "" {
+		v.cfg.Default = name
+	}
+
+	return &Group{
+		app:         v.app,
+		prefix:      v.cfg.Prefix,
+		middlewares: append([]MiddlewareFunc{version.middleware}, middlewares...),
+		version:     version,
+	}
+}
+
+func (v *Versioning) Deprecate(name string, deprecation Deprecation) {
+	name = normalizeVersion(name)
+	version, ok := v.versions[name]
+	if !ok {
+		version = &apiVersion{versioning: v, name: name}
+		v.versions[name] = version
+	}
+	version.deprecation = &deprecation
+}
+
+func (version *apiVersion) middleware(next HandlerFunc) HandlerFunc {
+	return func(z *Z) {
+		z.setContextValue(apiVersionContextKey, version.name)
+		if dep := version.deprecation; dep != nil {
+			header := z.rw.Header()
+			if dep.Date.IsZero() {
+				header.Set("Deprecation", "true")
+			} else {
+				header.Set("Deprecation", "@"+strconv.FormatInt(dep.Date.Unix(), 10))
+			}
+			if !dep.Sunset.IsZero() {
+				header.Set("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if dep.Link != "" {
+				header.Add("Link", "<"+dep.Link+`>; rel="deprecation"`)
+			}
+		}
+		next(z)
+	}
+}
+
+func (version *apiVersion) handle(g *Group, method, path string, handler HandlerFunc, middlewares []MiddlewareFunc) *Route {
+	v := version.versioning
+	rest := strings.TrimPrefix(g.prefix, v.cfg.Prefix) + path
+
+	var first *Route
+	if v.cfg.URLPrefix {
+		first = v.app.handle(&Route{
+			Method:  method,
+			Host:    g.host,
+			Pattern: v.cfg.Prefix + "/v" + version.name + rest,
+			Version: version.name,
+		}, handler, middlewares...)
+	}
+	if v.cfg.Header != "" || v.cfg.Vendor != "" {
+		route := v.app.handle(&Route{
+			Method:     method,
+			Host:       g.host,
+			Pattern:    v.cfg.Prefix + rest,
+			Version:    version.name,
+			versioning: v,
+		}, handler, append(middlewares, v.vary)...)
+		if first == nil {
+			first = route
+		}
+	}
+	return first
+}
+
+func (v *Versioning) vary(next HandlerFunc) HandlerFunc {
+	return func(z *Z) {
+		if v.cfg.Header != "" {
+			z.rw.Header().Add("Vary", v.cfg.Header)
+		}
+		if v.cfg.Vendor != "" {
+			z.rw.Header().Add("Vary", "Accept")
+		}
+		next(z)
+	}
+}
+
+func (v *Versioning) resolve(r *http.Request) string {
+	if v.cfg.Header != "" {
+		if value := r.Header.Get(v.cfg.Header); value != "" {
+			return normalizeVersion(value)
+		}
+	}
+	if v.cfg.Vendor != "" {
+		if version := vendorVersion(r.Header.Get("Accept"), v.cfg.Vendor); version != "" {
+			return version
+		}
+	}
+	return v.cfg.Default
+}
+
+func vendorVersion(accept, vendor string) string {
+	prefix := "application/vnd." + strings.ToLower(vendor)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		rest, ok := strings.CutPrefix(mediaType, prefix)
+		if !ok || (rest != "" && rest[0] != '.' && rest[0] != '+') {
+			continue
+		}
+		if version := params["version"]; version != "" {
+			return normalizeVersion(version)
+		}
+		if version, ok := strings.CutPrefix(rest, ".v"); ok {
+			version, _, _ = strings.Cut(version, "+")
+			return normalizeVersion(version)
+		}
+	}
+	return ""
+}
+
+func normalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	if len(version) > 1 && (version[0] == 'v' || version[0] == 'V') {
+		return version[1:]
+	}
+	return version
+}
+
+func (z *Z) APIVersion() string {
+	version, _ := z.r.Context().Value(apiVersionContextKey).(string)
+	return version
+}
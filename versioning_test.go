@@ -0,0 +1,114 @@
+package z
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVersioning(t *testing.T) {
+	app := New()
+	versions := app.Versioning(VersioningConfig{
+		Prefix:    "/api",
+		URLPrefix: true,
+		Header:    "X-API-Version",
+		Vendor:    "acme",
+		Default:   "v2",
+	})
+	handler := func(z *Z) { z.Ok(z.APIVersion() + ":" + z.PathValue("id")) }
+	versions.Version("1").GET("/users/{id}", handler)
+	versions.Version("2").Group("/users").GET("/{id}", handler)
+
+	cases := []struct {
+		target   string
+		header   string
+		value    string
+		wantCode int
+		wantBody string
+	}{
+		{"/api/v1/users/7", "", "", http.StatusOK, "1:7"},
+		{"/api/v2/users/7", "", "", http.StatusOK, "2:7"},
+		{"/api/v3/users/7", "", "", http.StatusNotFound, ""},
+		{"/api/users/7", "", "", http.StatusOK, "2:7"},
+		{"/api/users/7", "X-API-Version", "v1", http.StatusOK, "1:7"},
+		{"/api/users/7", "Accept", "application/vnd.acme.v1+json", http.StatusOK, "1:7"},
+		{"/api/users/7", "Accept", "text/html, application/vnd.acme+json; version=1", http.StatusOK, "1:7"},
+		{"/api/users/7", "Accept", "application/json", http.StatusOK, "2:7"},
+		{"/api/users/7", "Accept", "application/vnd.acmecorp+json; version=1", http.StatusOK, "2:7"},
+		{"/api/users/7", "Accept", "application/vnd.acmecorp.v1+json", http.StatusOK, "2:7"},
+		{"/api/users/7", "X-API-Version", "9", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.target, nil)
+		if c.header != "" {
+			req.Header.Set(c.header, c.value)
+		}
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, req)
+		if rr.Code != c.wantCode || (c.wantBody != "" && rr.Body.String() != c.wantBody) {
+			t.Errorf("%s %s=%q: expected %d %q, got %d %q", c.target, c.header, c.value, c.wantCode, c.wantBody, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/api/users/7", nil))
+	if vary := rr.Header().Values("Vary"); len(vary) != 2 {
+		t.Errorf("expected Vary on header and Accept, got %v", vary)
+	}
+	if err := app.Err(); err != nil {
+		t.Fatalf("unexpected route error: %v", err)
+	}
+}
+
+func TestVersioningDeprecation(t *testing.T) {
+	app := New()
+	versions := app.Versioning(VersioningConfig{})
+	versions.Version("v1").GET("/items", func(z *Z) { z.Ok(z.APIVersion()) })
+	versions.Version("v2").GET("/items", func(z *Z) { z.Ok(z.APIVersion()) })
+
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	versions.Deprecate("v1", Deprecation{Date: date, Sunset: sunset, Link: "https://example.com/migrate"})
+
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/items", nil))
+	if rr.Body.String() != "1" {
+		t.Errorf("expected version 1, got %q", rr.Body.String())
+	}
+	if got := rr.Header().Get("Deprecation"); got != "@1767225600" {
+		t.Errorf("unexpected Deprecation header %q", got)
+	}
+	if got := rr.Header().Get("Sunset"); got != "Thu, 31 Dec 2026 00:00:00 GMT" {
+		t.Errorf("unexpected Sunset header %q", got)
+	}
+	if got := rr.Header().Get("Link"); got != `<https://example.com/migrate>; rel="deprecation"` {
+		t.Errorf("unexpected Link header %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/v2/items", nil))
+	if rr.Body.String() != "2" || rr.Header().Get("Deprecation") != "" {
+		t.Errorf("expected undeprecated v2, got %q %v", rr.Body.String(), rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest("GET", "/items", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a URL version, got %d", rr.Code)
+	}
+}
+
+func TestVersioningConflict(t *testing.T) {
+	app := New()
+	versions := app.Versioning(VersioningConfig{Header: "X-API-Version"})
+	versions.Version("1").GET("/items", func(z *Z) {})
+	versions.Version("1").GET("/items", func(z *Z) {})
+	versions.Version("2").GET("/items", func(z *Z) {})
+	if err := app.Err(); err == nil {
+		t.Fatal("expected conflict for duplicate version route")
+	}
+	if len(app.Routes()) != 2 {
+		t.Errorf("expected 2 routes, got %d", len(app.Routes()))
+	}
+}
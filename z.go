@@ -1,19 +1,47 @@
 package z
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"net/netip"
 )
 
 type App struct {
-	mux         *http.ServeMux
-	middlewares []MiddlewareFunc
+	mux                *http.ServeMux
+	middlewares        []MiddlewareFunc
+	paths              map[string]*pathRoutes
+	autoOptions        bool
+	trustedProxies     []netip.Prefix
+	trustedProxyHeader string
+	metrics            *MetricsRegistry
+	routes             []*Route
+	slots              map[string]*routeSlot
+	routeErrors        []error
+	debug              bool
 }
 
 type Z struct {
-	rw http.ResponseWriter
-	r  *http.Request
+	rw    http.ResponseWriter
+	r     *http.Request
+	app   *App
+	route *Route
 }
 
+type contextKey int
+
+const (
+	requestIDContextKey contextKey = iota
+	loggerContextKey
+	spanContextKey
+	cspNonceContextKey
+	principalContextKey
+	sessionContextKey
+	csrfContextKey
+	cacheContextKey
+	apiVersionContextKey
+)
+
 func (app *App) Use(middlewareFunc MiddlewareFunc) {
 	app.middlewares = append(app.middlewares, middlewareFunc)
 }
@@ -26,6 +54,9 @@ func New() *App {
 	return &App{
 		mux:         http.NewServeMux(),
 		middlewares: []MiddlewareFunc{},
+		paths:       map[string]*pathRoutes{},
+		metrics:     NewMetricsRegistry(),
+		slots:       map[string]*routeSlot{},
 	}
 }
 
@@ -36,3 +67,31 @@ func (z *Z) ResponseWriter() http.ResponseWriter {
 func (z *Z) Request() *http.Request {
 	return z.r
 }
+
+func (z *Z) Context() context.Context {
+	return z.r.Context()
+}
+
+func (z *Z) setContextValue(key, value any) {
+	z.r = z.r.WithContext(context.WithValue(z.r.Context(), key, value))
+}
+
+func (z *Z) RequestID() string {
+	return RequestIDFromContext(z.r.Context())
+}
+
+func (z *Z) Logger() *slog.Logger {
+	return LoggerFromContext(z.r.Context())
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}